@@ -2,6 +2,7 @@ package bun
 
 import (
 	"context"
+	"errors"
 
 	"github.com/uptrace/bun/internal"
 	"github.com/uptrace/bun/schema"
@@ -22,6 +23,12 @@ type (
 	AfterScanRowHook  = schema.AfterScanRowHook
 )
 
+// ErrStaleObject is returned by Update and Delete queries on models with a `bun:",version"`
+// field when the row was modified or deleted since the model was loaded.
+// Bulk updates still update the rows with a matching version, so use a transaction
+// to roll them back.
+var ErrStaleObject = errors.New("bun: stale object: version mismatch")
+
 func SafeQuery(query string, args ...interface{}) schema.QueryWithArgs {
 	return schema.SafeQuery(query, args)
 }
@@ -58,6 +65,14 @@ type AfterDeleteHook interface {
 	AfterDelete(ctx context.Context, query *DeleteQuery) error
 }
 
+type BeforeRestoreHook interface {
+	BeforeRestore(ctx context.Context, query *RestoreQuery) error
+}
+
+type AfterRestoreHook interface {
+	AfterRestore(ctx context.Context, query *RestoreQuery) error
+}
+
 type BeforeCreateTableHook interface {
 	BeforeCreateTable(ctx context.Context, query *CreateTableQuery) error
 }
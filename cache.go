@@ -0,0 +1,416 @@
+package bun
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// Cache stores the results of select queries executed with SelectQuery.Cache.
+//
+// Entries are tagged with the cache key passed to SelectQuery.Cache and the names
+// of the tables the query reads from, so they can be invalidated by either.
+// Implementations backed by a remote store should treat errors as cache misses.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores the value for the ttl. A zero ttl means the value doesn't expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string)
+	// Invalidate removes the values stored with any of the tags.
+	Invalidate(ctx context.Context, tags ...string)
+}
+
+// WithCache configures the cache used by select queries executed with SelectQuery.Cache.
+// Insert, update, and delete queries invalidate the cached results of their table.
+// Queries executed in a transaction invalidate them again after the transaction commits.
+func WithCache(cache Cache) DBOption {
+	return func(db *DB) {
+		db.cache = cache
+		db.cacheTxs = new(sync.Map)
+	}
+}
+
+// InvalidateCache removes the cached query results that were stored with the cache keys
+// or read from the tables, for example, after the tables were modified with raw queries.
+func (db *DB) InvalidateCache(ctx context.Context, keysOrTables ...string) {
+	if db.cache != nil {
+		db.cache.Invalidate(ctx, keysOrTables...)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type queryCache struct {
+	key string
+	ttl time.Duration
+}
+
+// Cache caches the scanned values of the query for the ttl. The results are stored
+// by the formatted query in the cache configured with WithCache and are invalidated
+// by the insert, update, delete, restore, and merge queries of the tables the query reads from.
+// The key is used to tell the entries apart and to invalidate them with DB.InvalidateCache.
+//
+// The values are encoded with msgpack, so they must be encodable without losing data.
+// The cache is not used in transactions, when the DB has no cache, and when the tables
+// of the query are unknown, for example, because of raw joins added with Join.
+func (q *SelectQuery) Cache(key string, ttl time.Duration) *SelectQuery {
+	q.cache = &queryCache{key: key, ttl: ttl}
+	return q
+}
+
+// useCache reports whether the query results can be cached.
+func (q *SelectQuery) useCache() bool {
+	if q.cache == nil || q.db.cache == nil || q.conn != IConn(q.db.DB) {
+		return false
+	}
+	_, ok := q.selectCacheTables()
+	return ok
+}
+
+func (q *SelectQuery) cacheKey(query string) string {
+	return q.cache.key + ":" + query
+}
+
+// cacheTags returns the cache key and the tables of the query, its joins, and relations.
+func (q *SelectQuery) cacheTags(model Model) []string {
+	tags := []string{q.cache.key}
+	tables, _ := q.selectCacheTables()
+	tags = append(tags, tables...)
+	if tm, ok := model.(TableModel); ok {
+		tags = appendJoinTables(tags, tm.getJoins())
+	}
+	return tags
+}
+
+// selectCacheTables returns the tables the query reads from, including the tables
+// of the CTEs and the joined subqueries. It returns false when the tables can't be
+// determined, for example, when the query has raw joins.
+func (q *SelectQuery) selectCacheTables() ([]string, bool) {
+	var tables []string
+
+	for _, wq := range q.with {
+		sq, ok := wq.query.(*SelectQuery)
+		if !ok {
+			return nil, false
+		}
+		withTables, ok := sq.selectCacheTables()
+		if !ok {
+			return nil, false
+		}
+		tables = append(tables, withTables...)
+	}
+
+	queryTables, ok := q.cacheTables()
+	if !ok {
+		return nil, false
+	}
+	tables = append(tables, queryTables...)
+
+	for i := range q.joins {
+		j := &q.joins[i]
+		if j.subquery == nil {
+			return nil, false
+		}
+		joinTables, ok := j.subquery.selectCacheTables()
+		if !ok {
+			return nil, false
+		}
+		tables = append(tables, joinTables...)
+		if j.subquery.tableModel != nil {
+			tables = appendJoinTables(tables, j.subquery.tableModel.getJoins())
+		}
+	}
+
+	return tables, true
+}
+
+// cacheTables returns the unquoted names of the model table and the tables added
+// with Table and TableExpr. It returns false when a table expression is not a table name.
+func (q *baseQuery) cacheTables() (tables []string, ok bool) {
+	if q.table != nil {
+		tables = append(tables, q.table.Name)
+	}
+
+	exprs := q.tables
+	if !q.modelTableName.IsZero() {
+		exprs = append([]schema.QueryWithArgs{q.modelTableName}, exprs...)
+	}
+
+	ok = true
+	for _, expr := range exprs {
+		name, known := cacheTableName(expr)
+		if !known {
+			ok = false
+			continue
+		}
+		tables = append(tables, name)
+	}
+	return tables, ok
+}
+
+// cacheTableName returns the unquoted table name of an identifier like "public.users"
+// or a table expression like `"users" AS u`.
+func cacheTableName(table schema.QueryWithArgs) (string, bool) {
+	name := table.Query
+	if table.Args != nil {
+		if len(table.Args) > 0 {
+			return "", false
+		}
+		fields := strings.Fields(name)
+		if len(fields) == 0 || strings.ContainsAny(fields[0], "(),?") {
+			return "", false
+		}
+		name = fields[0]
+	}
+	return unquoteTableName.Replace(name), name != ""
+}
+
+var unquoteTableName = strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "")
+
+func appendJoinTables(tags []string, joins []relationJoin) []string {
+	for i := range joins {
+		j := &joins[i]
+		tags = append(tags, j.JoinModel.Table().Name)
+		if j.Relation.M2MTable != nil {
+			tags = append(tags, j.Relation.M2MTable.Name)
+		}
+		tags = appendJoinTables(tags, j.JoinModel.getJoins())
+	}
+	return tags
+}
+
+// cacheGet decodes the cached values into the dest and returns the number of rows.
+func (q *SelectQuery) cacheGet(
+	ctx context.Context, key string, model Model, dest []interface{},
+) (int, bool) {
+	b, ok := q.db.cache.Get(ctx, key)
+	if !ok {
+		return 0, false
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+
+	numRow, err := dec.DecodeInt()
+	if err != nil {
+		return 0, false
+	}
+	for _, v := range cacheValues(model, dest) {
+		if err := dec.Decode(v); err != nil {
+			return 0, false
+		}
+	}
+	return numRow, true
+}
+
+func (q *SelectQuery) cacheSet(
+	ctx context.Context, key string, model Model, dest []interface{}, numRow int,
+) error {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+
+	if err := enc.EncodeInt(int64(numRow)); err != nil {
+		return err
+	}
+	for _, v := range cacheValues(model, dest) {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("bun: can't cache %T: %w", v, err)
+		}
+	}
+
+	q.db.cache.Set(ctx, key, buf.Bytes(), q.cache.ttl, q.cacheTags(model))
+	return nil
+}
+
+func cacheValues(model Model, dest []interface{}) []interface{} {
+	if len(dest) > 0 {
+		return dest
+	}
+	return []interface{}{model.Value()}
+}
+
+// invalidateCache invalidates the cached results of the tables modified by the query.
+func (db *DB) invalidateCache(ctx context.Context, iquery Query, conn IConn) {
+	if db.cache == nil {
+		return
+	}
+	switch iquery.(type) {
+	case *InsertQuery, *UpdateQuery, *DeleteQuery, *RestoreQuery, *MergeQuery:
+	default:
+		return
+	}
+
+	tables, _ := iquery.(interface{ cacheTables() ([]string, bool) }).cacheTables()
+	if len(tables) == 0 {
+		return
+	}
+	db.cache.Invalidate(ctx, tables...)
+
+	// Concurrent queries don't see the changes until the transaction commits
+	// and can cache the old rows in the meantime.
+	if tx, ok := conn.(*sql.Tx); ok {
+		if v, ok := db.cacheTxs.Load(tx); ok {
+			v.(*cacheTx).add(tables...)
+		}
+	}
+}
+
+// cacheTx holds the tables modified in a transaction.
+type cacheTx struct {
+	mu     sync.Mutex
+	tables []string
+}
+
+func (tx *cacheTx) add(tables ...string) {
+	tx.mu.Lock()
+	tx.tables = append(tx.tables, tables...)
+	tx.mu.Unlock()
+}
+
+func (db *DB) beginCacheTx(tx *sql.Tx) {
+	if db.cache != nil {
+		db.cacheTxs.Store(tx, new(cacheTx))
+	}
+}
+
+// endCacheTx invalidates the tables modified in the transaction once it is committed.
+func (db *DB) endCacheTx(ctx context.Context, tx *sql.Tx, commit bool) {
+	if db.cache == nil {
+		return
+	}
+	v, ok := db.cacheTxs.LoadAndDelete(tx)
+	if !ok || !commit {
+		return
+	}
+
+	txCache := v.(*cacheTx)
+	txCache.mu.Lock()
+	defer txCache.mu.Unlock()
+	if len(txCache.tables) > 0 {
+		db.cache.Invalidate(ctx, txCache.tables...)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// LRUCache is an in-memory Cache that evicts the least recently used entries.
+type LRUCache struct {
+	size int
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[string]*list.Element
+	tags    map[string]map[string]struct{}
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	tags      []string
+	expiresAt time.Time
+}
+
+// NewLRUCache returns an in-memory cache that holds up to size entries.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(
+	ctx context.Context, key string, value []byte, ttl time.Duration, tags []string,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	entry := &lruEntry{
+		key:   key,
+		value: value,
+		tags:  tags,
+	}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.entries[key] = c.ll.PushFront(entry)
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for c.size > 0 && c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *LRUCache) Invalidate(ctx context.Context, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if el, ok := c.entries[key]; ok {
+				c.remove(el)
+			}
+		}
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	entry := el.Value.(*lruEntry)
+	c.ll.Remove(el)
+	delete(c.entries, entry.key)
+	for _, tag := range entry.tags {
+		keys := c.tags[tag]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package bun
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithCommenter configures a function that returns the key/values appended to queries
+// as a sqlcommenter comment, for example, the route and the trace id of the request.
+// It can be used multiple times; later functions override the keys of earlier ones.
+func WithCommenter(fn func(ctx context.Context) map[string]string) DBOption {
+	return func(db *DB) {
+		db.commenters = append(db.commenters, fn)
+	}
+}
+
+// WithCallerComment appends the function, file, and line that executed the query
+// to the sqlcommenter comment using the "caller" key.
+func WithCallerComment() DBOption {
+	return WithCommenter(func(ctx context.Context) map[string]string {
+		caller := callerFrame()
+		fn := caller.Function
+		if ind := strings.LastIndexByte(fn, '/'); ind != -1 {
+			fn = fn[ind+1:]
+		}
+		file := caller.File
+		if ind := strings.LastIndexByte(file, '/'); ind != -1 {
+			file = file[ind+1:]
+		}
+		return map[string]string{
+			"caller": fn + " " + file + ":" + strconv.Itoa(caller.Line),
+		}
+	})
+}
+
+type commentCtxKey struct{}
+
+// WithCommentTags returns a context that appends the key/values to the sqlcommenter
+// comment of queries executed with it. The key/values are merged with the ones
+// of the parent context and override the ones returned by the commenters.
+func WithCommentTags(ctx context.Context, tags map[string]string) context.Context {
+	parent, _ := ctx.Value(commentCtxKey{}).(map[string]string)
+	merged := make(map[string]string, len(parent)+len(tags))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, commentCtxKey{}, merged)
+}
+
+// commentQuery appends the sqlcommenter comment to the query.
+// The comment is appended after the query is built, so it does not change
+// the cache keys of queries and database-side normalization strips it.
+func (db *DB) commentQuery(ctx context.Context, query string) string {
+	tags, _ := ctx.Value(commentCtxKey{}).(map[string]string)
+	if len(db.commenters) > 0 {
+		merged := make(map[string]string, len(tags))
+		for _, fn := range db.commenters {
+			for k, v := range fn(ctx) {
+				merged[k] = v
+			}
+		}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		tags = merged
+	}
+	if len(tags) == 0 {
+		return query
+	}
+	return string(appendSQLComment([]byte(query), tags))
+}
+
+// appendSQLComment appends the key/values in the sqlcommenter format:
+// the keys are sorted and the keys and values are URL-encoded.
+func appendSQLComment(b []byte, tags map[string]string) []byte {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return b
+	}
+	sort.Strings(keys)
+
+	b = append(b, " /*"...)
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendURLEncoded(b, k)
+		b = append(b, "='"...)
+		b = appendURLEncoded(b, tags[k])
+		b = append(b, '\'')
+	}
+	b = append(b, "*/"...)
+	return b
+}
+
+// appendURLEncoded percent-encodes all bytes except the unreserved characters,
+// so the value can't contain quotes or end the comment.
+func appendURLEncoded(b []byte, s string) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b = append(b, c)
+		default:
+			b = append(b, '%', hex[c>>4], hex[c&15])
+		}
+	}
+	return b
+}
+
+// prependComment writes the comment set with Comment before the query,
+// so it must be called before the query is appended to b.
+func prependComment(b []byte, comment string) []byte {
+	if comment == "" {
+		return b
+	}
+	comment = strings.ReplaceAll(comment, "\x00", "")
+	comment = strings.ReplaceAll(comment, "/*", `/\*`)
+	comment = strings.ReplaceAll(comment, "*/", `*\/`)
+	b = append(b, "/* "...)
+	b = append(b, comment...)
+	b = append(b, " */ "...)
+	return b
+}
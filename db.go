@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/uptrace/bun/dialect/feature"
 	"github.com/uptrace/bun/internal"
@@ -32,6 +34,15 @@ func WithDiscardUnknownColumns() DBOption {
 	}
 }
 
+// WithSchemaResolver configures a function that returns the schema of model tables
+// for the query context, for example, to route queries to a schema per tenant.
+// The schema set with DB.WithSchema takes precedence over the resolver.
+func WithSchemaResolver(fn func(ctx context.Context) string) DBOption {
+	return func(db *DB) {
+		db.schemaResolver = fn
+	}
+}
+
 type DB struct {
 	*sql.DB
 
@@ -39,9 +50,19 @@ type DB struct {
 	features feature.Feature
 
 	queryHooks []QueryHook
+	// redactions are the redactions requested by RedactionHook hooks.
+	redactions internal.Flag
 
 	fmter schema.Formatter
 	flags internal.Flag
+	clock func() time.Time
+
+	schemaResolver func(ctx context.Context) string
+	replicas       *replicaSet
+	txRetry        txRetry
+	cache          Cache
+	cacheTxs       *sync.Map
+	commenters     []func(ctx context.Context) map[string]string
 
 	stats DBStats
 }
@@ -102,6 +123,10 @@ func (db *DB) NewDelete() *DeleteQuery {
 	return NewDeleteQuery(db)
 }
 
+func (db *DB) NewRestore() *RestoreQuery {
+	return NewRestoreQuery(db)
+}
+
 func (db *DB) NewRaw(query string, args ...interface{}) *RawQuery {
 	return NewRawQuery(db, query, args...)
 }
@@ -134,6 +159,12 @@ func (db *DB) NewDropColumn() *DropColumnQuery {
 	return NewDropColumnQuery(db)
 }
 
+// M2M returns a helper that manages the join rows of the many-to-many relation
+// with the given name, for example, db.M2M(&post, "Tags").Attach(ctx, tags).
+func (db *DB) M2M(model interface{}, name string) *M2M {
+	return NewM2M(db, model, name)
+}
+
 func (db *DB) ResetModel(ctx context.Context, models ...interface{}) error {
 	for _, model := range models {
 		if _, err := db.NewDropTable().Model(model).IfExists().Cascade().Exec(ctx); err != nil {
@@ -188,9 +219,17 @@ func (db *DB) AddQueryHook(hook QueryHook) {
 	if initer, ok := hook.(queryHookIniter); ok {
 		initer.Init(db)
 	}
+	if hook, ok := hook.(RedactionHook); ok && hook.Redaction() != RedactNone {
+		db.redactions = db.redactions.Set(hook.Redaction().flag())
+	}
 	db.queryHooks = append(db.queryHooks, hook)
 }
 
+// QueryHooks returns a copy of the query hooks added with AddQueryHook.
+func (db *DB) QueryHooks() []QueryHook {
+	return append([]QueryHook(nil), db.queryHooks...)
+}
+
 func (db *DB) Table(typ reflect.Type) *schema.Table {
 	return db.dialect.Tables().Get(typ)
 }
@@ -216,10 +255,54 @@ func (db *DB) WithNamedArg(name string, value interface{}) *DB {
 	return clone
 }
 
+// WithClock returns a copy of the DB that uses the clock to set created_at,
+// updated_at, and soft_delete fields. It is mostly useful in tests.
+func (db *DB) WithClock(clock func() time.Time) *DB {
+	clone := db.clone()
+	clone.clock = clock
+	return clone
+}
+
+func (db *DB) now() time.Time {
+	if db.clock != nil {
+		return db.clock()
+	}
+	return time.Now()
+}
+
+type schemaCtxKey struct{}
+
+// WithSchema returns a context that makes queries executed with it target
+// model tables in the schema, for example, "tenant_1"."users" instead of "users".
+// Tables that specify a schema in the model are left unchanged.
+// Use ?TableName in ModelTableExpr to get the table name with the schema.
+func (db *DB) WithSchema(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, schemaCtxKey{}, name)
+}
+
+// schemaName returns the schema of model tables for the query context.
+func (db *DB) schemaName(ctx context.Context) string {
+	if name, ok := ctx.Value(schemaCtxKey{}).(string); ok {
+		return name
+	}
+	if db.schemaResolver != nil {
+		return db.schemaResolver(ctx)
+	}
+	return ""
+}
+
 func (db *DB) Formatter() schema.Formatter {
 	return db.fmter
 }
 
+// formatter returns the formatter for queries executed with the context.
+func (db *DB) formatter(ctx context.Context) schema.Formatter {
+	if name := db.schemaName(ctx); name != "" {
+		return db.fmter.WithTableSchema(name)
+	}
+	return db.fmter
+}
+
 // UpdateFQN returns a fully qualified column name. For MySQL, it returns the column name with
 // the table alias. For other RDBMS, it returns just the column name.
 func (db *DB) UpdateFQN(alias, column string) Ident {
@@ -243,8 +326,8 @@ func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 func (db *DB) ExecContext(
 	ctx context.Context, query string, args ...interface{},
 ) (sql.Result, error) {
-	formattedQuery := db.format(query, args)
-	ctx, event := db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := db.commentQuery(ctx, db.format(query, args))
+	ctx, event := db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, db.DB)
 	res, err := db.DB.ExecContext(ctx, formattedQuery)
 	db.afterQuery(ctx, event, res, err)
 	return res, err
@@ -257,8 +340,8 @@ func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 func (db *DB) QueryContext(
 	ctx context.Context, query string, args ...interface{},
 ) (*sql.Rows, error) {
-	formattedQuery := db.format(query, args)
-	ctx, event := db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := db.commentQuery(ctx, db.format(query, args))
+	ctx, event := db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, db.DB)
 	rows, err := db.DB.QueryContext(ctx, formattedQuery)
 	db.afterQuery(ctx, event, nil, err)
 	return rows, err
@@ -269,8 +352,8 @@ func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 }
 
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	formattedQuery := db.format(query, args)
-	ctx, event := db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := db.commentQuery(ctx, db.format(query, args))
+	ctx, event := db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, db.DB)
 	row := db.DB.QueryRowContext(ctx, formattedQuery)
 	db.afterQuery(ctx, event, nil, row.Err())
 	return row
@@ -301,8 +384,8 @@ func (db *DB) Conn(ctx context.Context) (Conn, error) {
 func (c Conn) ExecContext(
 	ctx context.Context, query string, args ...interface{},
 ) (sql.Result, error) {
-	formattedQuery := c.db.format(query, args)
-	ctx, event := c.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := c.db.commentQuery(ctx, c.db.format(query, args))
+	ctx, event := c.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, c.Conn)
 	res, err := c.Conn.ExecContext(ctx, formattedQuery)
 	c.db.afterQuery(ctx, event, res, err)
 	return res, err
@@ -311,16 +394,16 @@ func (c Conn) ExecContext(
 func (c Conn) QueryContext(
 	ctx context.Context, query string, args ...interface{},
 ) (*sql.Rows, error) {
-	formattedQuery := c.db.format(query, args)
-	ctx, event := c.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := c.db.commentQuery(ctx, c.db.format(query, args))
+	ctx, event := c.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, c.Conn)
 	rows, err := c.Conn.QueryContext(ctx, formattedQuery)
 	c.db.afterQuery(ctx, event, nil, err)
 	return rows, err
 }
 
 func (c Conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	formattedQuery := c.db.format(query, args)
-	ctx, event := c.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := c.db.commentQuery(ctx, c.db.format(query, args))
+	ctx, event := c.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, c.Conn)
 	row := c.Conn.QueryRowContext(ctx, formattedQuery)
 	c.db.afterQuery(ctx, event, nil, row.Err())
 	return row
@@ -354,6 +437,10 @@ func (c Conn) NewDelete() *DeleteQuery {
 	return NewDeleteQuery(c.db).Conn(c)
 }
 
+func (c Conn) NewRestore() *RestoreQuery {
+	return NewRestoreQuery(c.db).Conn(c)
+}
+
 func (c Conn) NewRaw(query string, args ...interface{}) *RawQuery {
 	return NewRawQuery(c.db, query, args...).Conn(c)
 }
@@ -391,38 +478,26 @@ func (c Conn) NewDropColumn() *DropColumnQuery {
 func (c Conn) RunInTx(
 	ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx Tx) error,
 ) error {
-	tx, err := c.BeginTx(ctx, opts)
-	if err != nil {
-		return err
-	}
-
-	var done bool
-
-	defer func() {
-		if !done {
-			_ = tx.Rollback()
-		}
-	}()
-
-	if err := fn(ctx, tx); err != nil {
-		return err
-	}
-
-	done = true
-	return tx.Commit()
+	return c.db.runInTx(ctx, func(ctx context.Context) (Tx, error) {
+		return c.BeginTx(ctx, opts)
+	}, fn)
 }
 
 func (c Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
-	ctx, event := c.db.beforeQuery(ctx, nil, "BEGIN", nil, "BEGIN", nil)
-	tx, err := c.Conn.BeginTx(ctx, opts)
-	c.db.afterQuery(ctx, event, nil, err)
+	ctx, txEvent := c.db.beforeTx(ctx, "")
+	queryCtx, event := c.db.beforeQuery(ctx, nil, "BEGIN", nil, "BEGIN", nil, c.Conn)
+	tx, err := c.Conn.BeginTx(queryCtx, opts)
+	c.db.afterQuery(queryCtx, event, nil, err)
 	if err != nil {
+		c.db.afterTx(ctx, txEvent, "BEGIN", err)
 		return Tx{}, err
 	}
+	c.db.beginCacheTx(tx)
 	return Tx{
-		ctx: ctx,
-		db:  c.db,
-		Tx:  tx,
+		ctx:   ctx,
+		db:    c.db,
+		Tx:    tx,
+		event: txEvent,
 	}, nil
 }
 
@@ -452,32 +527,18 @@ type Tx struct {
 	// name is the name of a savepoint
 	name string
 	*sql.Tx
+
+	event *TxEvent
 }
 
-// RunInTx runs the function in a transaction. If the function returns an error,
-// the transaction is rolled back. Otherwise, the transaction is committed.
+// RunInTx runs the function in a transaction. The transaction is committed
+// if the function returns nil and rolled back otherwise. See WithTxRetry.
 func (db *DB) RunInTx(
 	ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx Tx) error,
 ) error {
-	tx, err := db.BeginTx(ctx, opts)
-	if err != nil {
-		return err
-	}
-
-	var done bool
-
-	defer func() {
-		if !done {
-			_ = tx.Rollback()
-		}
-	}()
-
-	if err := fn(ctx, tx); err != nil {
-		return err
-	}
-
-	done = true
-	return tx.Commit()
+	return db.runInTx(ctx, func(ctx context.Context) (Tx, error) {
+		return db.BeginTx(ctx, opts)
+	}, fn)
 }
 
 func (db *DB) Begin() (Tx, error) {
@@ -485,16 +546,20 @@ func (db *DB) Begin() (Tx, error) {
 }
 
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
-	ctx, event := db.beforeQuery(ctx, nil, "BEGIN", nil, "BEGIN", nil)
-	tx, err := db.DB.BeginTx(ctx, opts)
-	db.afterQuery(ctx, event, nil, err)
+	ctx, txEvent := db.beforeTx(ctx, "")
+	queryCtx, event := db.beforeQuery(ctx, nil, "BEGIN", nil, "BEGIN", nil, db.DB)
+	tx, err := db.DB.BeginTx(queryCtx, opts)
+	db.afterQuery(queryCtx, event, nil, err)
 	if err != nil {
+		db.afterTx(ctx, txEvent, "BEGIN", err)
 		return Tx{}, err
 	}
+	db.beginCacheTx(tx)
 	return Tx{
-		ctx: ctx,
-		db:  db,
-		Tx:  tx,
+		ctx:   ctx,
+		db:    db,
+		Tx:    tx,
+		event: txEvent,
 	}, nil
 }
 
@@ -506,18 +571,22 @@ func (tx Tx) Commit() error {
 }
 
 func (tx Tx) commitTX() error {
-	ctx, event := tx.db.beforeQuery(tx.ctx, nil, "COMMIT", nil, "COMMIT", nil)
+	ctx, event := tx.db.beforeQuery(tx.ctx, nil, "COMMIT", nil, "COMMIT", nil, tx.Tx)
 	err := tx.Tx.Commit()
 	tx.db.afterQuery(ctx, event, nil, err)
+	tx.db.endCacheTx(tx.ctx, tx.Tx, true)
+	tx.db.afterTx(tx.ctx, tx.event, "COMMIT", err)
 	return err
 }
 
 func (tx Tx) commitSP() error {
 	if tx.Dialect().Features().Has(feature.MSSavepoint) {
+		tx.db.afterTx(tx.ctx, tx.event, "COMMIT", nil)
 		return nil
 	}
 	query := "RELEASE SAVEPOINT " + tx.name
 	_, err := tx.ExecContext(tx.ctx, query)
+	tx.db.afterTx(tx.ctx, tx.event, "COMMIT", err)
 	return err
 }
 
@@ -529,9 +598,11 @@ func (tx Tx) Rollback() error {
 }
 
 func (tx Tx) rollbackTX() error {
-	ctx, event := tx.db.beforeQuery(tx.ctx, nil, "ROLLBACK", nil, "ROLLBACK", nil)
+	ctx, event := tx.db.beforeQuery(tx.ctx, nil, "ROLLBACK", nil, "ROLLBACK", nil, tx.Tx)
 	err := tx.Tx.Rollback()
 	tx.db.afterQuery(ctx, event, nil, err)
+	tx.db.endCacheTx(tx.ctx, tx.Tx, false)
+	tx.db.afterTx(tx.ctx, tx.event, "ROLLBACK", err)
 	return err
 }
 
@@ -541,6 +612,7 @@ func (tx Tx) rollbackSP() error {
 		query = "ROLLBACK TRANSACTION " + tx.name
 	}
 	_, err := tx.ExecContext(tx.ctx, query)
+	tx.db.afterTx(tx.ctx, tx.event, "ROLLBACK", err)
 	return err
 }
 
@@ -551,8 +623,8 @@ func (tx Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
 func (tx Tx) ExecContext(
 	ctx context.Context, query string, args ...interface{},
 ) (sql.Result, error) {
-	formattedQuery := tx.db.format(query, args)
-	ctx, event := tx.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := tx.db.commentQuery(ctx, tx.db.format(query, args))
+	ctx, event := tx.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, tx.Tx)
 	res, err := tx.Tx.ExecContext(ctx, formattedQuery)
 	tx.db.afterQuery(ctx, event, res, err)
 	return res, err
@@ -565,8 +637,8 @@ func (tx Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
 func (tx Tx) QueryContext(
 	ctx context.Context, query string, args ...interface{},
 ) (*sql.Rows, error) {
-	formattedQuery := tx.db.format(query, args)
-	ctx, event := tx.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := tx.db.commentQuery(ctx, tx.db.format(query, args))
+	ctx, event := tx.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, tx.Tx)
 	rows, err := tx.Tx.QueryContext(ctx, formattedQuery)
 	tx.db.afterQuery(ctx, event, nil, err)
 	return rows, err
@@ -577,8 +649,8 @@ func (tx Tx) QueryRow(query string, args ...interface{}) *sql.Row {
 }
 
 func (tx Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	formattedQuery := tx.db.format(query, args)
-	ctx, event := tx.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil)
+	formattedQuery := tx.db.commentQuery(ctx, tx.db.format(query, args))
+	ctx, event := tx.db.beforeQuery(ctx, nil, query, args, formattedQuery, nil, tx.Tx)
 	row := tx.Tx.QueryRowContext(ctx, formattedQuery)
 	tx.db.afterQuery(ctx, event, nil, row.Err())
 	return row
@@ -604,40 +676,30 @@ func (tx Tx) BeginTx(ctx context.Context, _ *sql.TxOptions) (Tx, error) {
 	if tx.Dialect().Features().Has(feature.MSSavepoint) {
 		query = "SAVE TRANSACTION " + qName
 	}
+
+	ctx, txEvent := tx.db.beforeTx(ctx, qName)
 	_, err = tx.ExecContext(ctx, query)
 	if err != nil {
+		tx.db.afterTx(ctx, txEvent, "BEGIN", err)
 		return Tx{}, err
 	}
 	return Tx{
-		ctx:  ctx,
-		db:   tx.db,
-		Tx:   tx.Tx,
-		name: qName,
+		ctx:   ctx,
+		db:    tx.db,
+		Tx:    tx.Tx,
+		name:  qName,
+		event: txEvent,
 	}, nil
 }
 
+// RunInTx runs the function in a savepoint. The savepoint is not retried,
+// because it can't be retried without retrying the whole transaction.
 func (tx Tx) RunInTx(
 	ctx context.Context, _ *sql.TxOptions, fn func(ctx context.Context, tx Tx) error,
 ) error {
-	sp, err := tx.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-
-	var done bool
-
-	defer func() {
-		if !done {
-			_ = sp.Rollback()
-		}
-	}()
-
-	if err := fn(ctx, sp); err != nil {
-		return err
-	}
-
-	done = true
-	return sp.Commit()
+	return runTxOnce(ctx, func(ctx context.Context) (Tx, error) {
+		return tx.BeginTx(ctx, nil)
+	}, fn)
 }
 
 func (tx Tx) Dialect() schema.Dialect {
@@ -668,6 +730,10 @@ func (tx Tx) NewDelete() *DeleteQuery {
 	return NewDeleteQuery(tx.db).Conn(tx)
 }
 
+func (tx Tx) NewRestore() *RestoreQuery {
+	return NewRestoreQuery(tx.db).Conn(tx)
+}
+
 func (tx Tx) NewRaw(query string, args ...interface{}) *RawQuery {
 	return NewRawQuery(tx.db, query, args...).Conn(tx)
 }
@@ -35,4 +35,6 @@ const (
 	UpdateOrderLimit // UPDATE ... ORDER BY ... LIMIT ...
 	DeleteOrderLimit // DELETE ... ORDER BY ... LIMIT ...
 	DeleteReturning
+	WindowFunctions // ROW_NUMBER() OVER (PARTITION BY ...)
+	LateralJoin     // LEFT JOIN LATERAL or OUTER APPLY
 )
@@ -48,7 +48,9 @@ func New() *Dialect {
 		feature.Output |
 		feature.OffsetFetch |
 		feature.UpdateFromTable |
-		feature.MSSavepoint
+		feature.MSSavepoint |
+		feature.WindowFunctions |
+		feature.LateralJoin
 	return d
 }
 
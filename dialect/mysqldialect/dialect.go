@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -82,6 +83,9 @@ func (d *Dialect) Init(db *sql.DB) {
 		if semver.Compare(version, "v10.0.5") >= 0 {
 			d.features |= feature.DeleteReturning
 		}
+		if semver.Compare(version, "v10.2") >= 0 {
+			d.features |= feature.WindowFunctions
+		}
 		if semver.Compare(version, "v10.5.0") >= 0 {
 			d.features |= feature.InsertReturning
 		}
@@ -90,7 +94,10 @@ func (d *Dialect) Init(db *sql.DB) {
 
 	version = "v" + cleanupVersion(version)
 	if semver.Compare(version, "v8.0") >= 0 {
-		d.features |= feature.CTE | feature.WithValues
+		d.features |= feature.CTE | feature.WithValues | feature.WindowFunctions
+	}
+	if semver.Compare(version, "v8.0.14") >= 0 {
+		d.features |= feature.LateralJoin
 	}
 	if semver.Compare(version, "v8.0.16") >= 0 {
 		d.features |= feature.DeleteTableAlias
@@ -215,6 +222,43 @@ func (d *Dialect) DefaultSchema() string {
 	return "mydb"
 }
 
+// IsTxRetryable reports whether the error is a deadlock (1213) or a lock wait timeout (1205).
+func (d *Dialect) IsTxRetryable(err error) bool {
+	switch errorNumber(err) {
+	case 1205, 1213:
+		return true
+	}
+	return false
+}
+
+// errorNumber returns the MySQL error number of the driver error. The driver is not
+// imported, so the error is recognized by the Number field, for example,
+// of *mysql.MySQLError returned by go-sql-driver/mysql.
+func errorNumber(err error) uint16 {
+	if err == nil {
+		return 0
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Number"); f.IsValid() && f.Kind() == reflect.Uint16 {
+			return uint16(f.Uint())
+		}
+	}
+
+	switch err := err.(type) {
+	case interface{ Unwrap() error }:
+		return errorNumber(err.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			if n := errorNumber(err); n != 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
 func sqlType(field *schema.Field) string {
 	if field.DiscoveredSQLType == sqltype.Timestamp {
 		return datetimeType
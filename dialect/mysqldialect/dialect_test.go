@@ -0,0 +1,38 @@
+package mysqldialect
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mysqlError has the same fields as *mysqlError of go-sql-driver/mysql.
+type mysqlError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (e *mysqlError) Error() string { return e.Message }
+
+func TestIsTxRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&mysqlError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{&mysqlError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{fmt.Errorf("commit: %w", &mysqlError{Number: 1213}), true},
+		{errors.Join(errors.New("rollback"), &mysqlError{Number: 1205}), true},
+		{&mysqlError{Number: 1062, Message: "Duplicate entry"}, false},
+		{errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), false},
+		{nil, false},
+	}
+
+	d := New()
+	for _, test := range tests {
+		require.Equal(t, test.retryable, d.IsTxRetryable(test.err), test.err)
+	}
+}
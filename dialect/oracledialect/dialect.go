@@ -41,7 +41,9 @@ func New() *Dialect {
 		feature.SelectExists |
 		feature.AutoIncrement |
 		feature.CompositeIn |
-		feature.DeleteReturning
+		feature.DeleteReturning |
+		feature.WindowFunctions |
+		feature.LateralJoin
 	return d
 }
 
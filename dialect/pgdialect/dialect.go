@@ -2,6 +2,7 @@ package pgdialect
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -33,6 +34,7 @@ type Dialect struct {
 var _ schema.Dialect = (*Dialect)(nil)
 var _ sqlschema.InspectorDialect = (*Dialect)(nil)
 var _ sqlschema.MigratorDialect = (*Dialect)(nil)
+var _ schema.TxRetryChecker = (*Dialect)(nil)
 
 func New() *Dialect {
 	d := new(Dialect)
@@ -54,7 +56,9 @@ func New() *Dialect {
 		feature.SelectExists |
 		feature.GeneratedIdentity |
 		feature.CompositeIn |
-		feature.DeleteReturning
+		feature.DeleteReturning |
+		feature.WindowFunctions |
+		feature.LateralJoin
 	return d
 }
 
@@ -127,6 +131,28 @@ func (d *Dialect) AppendSequence(b []byte, _ *schema.Table, _ *schema.Field) []b
 	return appendGeneratedAsIdentity(b)
 }
 
+// IsTxRetryable reports whether the error is a serialization failure (40001)
+// or a deadlock (40P01). It supports errors returned by pgdriver and pgx.
+func (d *Dialect) IsTxRetryable(err error) bool {
+	switch sqlState(err) {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+func sqlState(err error) string {
+	var pgxErr interface{ SQLState() string }
+	if errors.As(err, &pgxErr) {
+		return pgxErr.SQLState()
+	}
+	var pgdriverErr interface{ Field(k byte) string }
+	if errors.As(err, &pgdriverErr) {
+		return pgdriverErr.Field('C')
+	}
+	return ""
+}
+
 // appendGeneratedAsIdentity appends GENERATED BY DEFAULT AS IDENTITY to the column definition.
 func appendGeneratedAsIdentity(b []byte) []byte {
 	return append(b, " GENERATED BY DEFAULT AS IDENTITY"...)
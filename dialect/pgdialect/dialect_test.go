@@ -0,0 +1,45 @@
+package pgdialect
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type pgxError struct{ code string }
+
+func (e *pgxError) Error() string    { return "pgx: " + e.code }
+func (e *pgxError) SQLState() string { return e.code }
+
+type pgdriverError struct{ code string }
+
+func (e pgdriverError) Error() string { return "pgdriver: " + e.code }
+
+func (e pgdriverError) Field(k byte) string {
+	if k == 'C' {
+		return e.code
+	}
+	return ""
+}
+
+func TestIsTxRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&pgxError{"40001"}, true},
+		{&pgxError{"40P01"}, true},
+		{&pgxError{"23505"}, false},
+		{pgdriverError{"40001"}, true},
+		{fmt.Errorf("commit: %w", pgdriverError{"40P01"}), true},
+		{pgdriverError{"57014"}, false},
+		{errors.New("40001"), false},
+	}
+
+	d := New()
+	for _, test := range tests {
+		require.Equal(t, test.retryable, d.IsTxRetryable(test.err), test.err)
+	}
+}
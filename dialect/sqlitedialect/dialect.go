@@ -41,7 +41,8 @@ func New() *Dialect {
 		feature.SelectExists |
 		feature.AutoIncrement |
 		feature.CompositeIn |
-		feature.DeleteReturning
+		feature.DeleteReturning |
+		feature.WindowFunctions
 	return d
 }
 
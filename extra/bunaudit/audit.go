@@ -0,0 +1,387 @@
+package bunaudit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	opInsert = "INSERT"
+	opUpdate = "UPDATE"
+	opDelete = "DELETE"
+)
+
+// sensitiveValue replaces the values of the fields with the sensitive tag option.
+const sensitiveValue = "***"
+
+// Entry is an audit log entry that records the change of a row.
+// Use it to create the audit log table:
+//
+//	db.NewCreateTable().Model((*bunaudit.Entry)(nil)).Exec(ctx)
+type Entry struct {
+	bun.BaseModel `bun:"table:audit_log,alias:audit_log"`
+
+	ID        int64 `bun:",pk,autoincrement"`
+	TableName string
+	Operation string
+	// PK contains the primary key values of the row separated by commas.
+	PK    string
+	Actor string
+	// Before and After contain the column values before and after the change.
+	// Updates record only the changed columns.
+	Before    map[string]interface{}
+	After     map[string]interface{}
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+type actorCtxKey struct{}
+
+// WithActor returns a context that records the actor in the audit entries
+// of the queries executed with it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+func actorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorCtxKey{}).(string)
+	return actor
+}
+
+// QueryHook records the changes of the models that embed Audited in the audit log table.
+// The entries are inserted using the connection of the query, so they are written
+// in the same transaction when the query is executed in one.
+type QueryHook struct {
+	table string
+	actor func(ctx context.Context) string
+
+	// pending contains the rows selected before update and delete queries.
+	pending sync.Map
+}
+
+var _ bun.QueryHook = (*QueryHook)(nil)
+
+// NewQueryHook initializes a new QueryHook with the given options.
+func NewQueryHook(opts ...Option) *QueryHook {
+	h := &QueryHook{
+		table: "audit_log",
+		actor: actorFromContext,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// BeforeQuery is called before a query is executed.
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery discards the rows selected for the query when the query fails.
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Err != nil && event.IQuery != nil {
+		h.pending.Delete(event.IQuery)
+	}
+}
+
+// hookFor returns the QueryHook added to the DB of the query.
+func hookFor(db *bun.DB) *QueryHook {
+	for _, hook := range db.QueryHooks() {
+		if h, ok := hook.(*QueryHook); ok {
+			return h
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// Audited is embedded in the models whose changes are recorded in the audit log.
+// It implements the insert, update, and delete model hooks, so models that implement
+// these hooks themselves must call the Audited methods from them.
+//
+// Updates and deletes select the affected rows with SelectQuery.WhereFrom before
+// the query is executed. Execute them in a transaction to lock the selected rows
+// until the query is executed.
+type Audited struct{}
+
+var (
+	_ bun.AfterInsertHook  = (*Audited)(nil)
+	_ bun.BeforeUpdateHook = (*Audited)(nil)
+	_ bun.AfterUpdateHook  = (*Audited)(nil)
+	_ bun.BeforeDeleteHook = (*Audited)(nil)
+	_ bun.AfterDeleteHook  = (*Audited)(nil)
+)
+
+func (Audited) AfterInsert(ctx context.Context, q *bun.InsertQuery) error {
+	h := hookFor(q.DB())
+	if h == nil {
+		return nil
+	}
+	return h.afterInsert(ctx, q)
+}
+
+func (Audited) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	h := hookFor(q.DB())
+	if h == nil {
+		return nil
+	}
+	return h.selectBefore(ctx, q)
+}
+
+func (Audited) AfterUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	h := hookFor(q.DB())
+	if h == nil {
+		return nil
+	}
+	return h.afterUpdate(ctx, q)
+}
+
+func (Audited) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	h := hookFor(q.DB())
+	if h == nil {
+		return nil
+	}
+	return h.selectBefore(ctx, q)
+}
+
+func (Audited) AfterDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	h := hookFor(q.DB())
+	if h == nil {
+		return nil
+	}
+	return h.afterDelete(ctx, q)
+}
+
+//------------------------------------------------------------------------------
+
+type auditQuery interface {
+	bun.Query
+	DB() *bun.DB
+	GetConn() bun.IConn
+}
+
+func tableOf(q bun.Query) *schema.Table {
+	if tm, ok := q.GetModel().(bun.TableModel); ok {
+		return tm.Table()
+	}
+	return nil
+}
+
+func (h *QueryHook) afterInsert(ctx context.Context, q *bun.InsertQuery) error {
+	table := tableOf(q)
+	if table == nil {
+		return nil
+	}
+
+	var entries []Entry
+	forEachRow(reflect.ValueOf(q.GetModel().Value()), func(strct reflect.Value) {
+		entries = append(entries, h.newEntry(ctx, table, opInsert, strct, nil, rowValues(table, strct)))
+	})
+	return h.insert(ctx, q, entries)
+}
+
+// selectBefore selects the rows that are modified by the query. The rows are locked
+// with FOR UPDATE when the dialect supports it, so concurrent queries can't change them
+// before the query is executed when it is executed in a transaction.
+func (h *QueryHook) selectBefore(ctx context.Context, q auditQuery) error {
+	table := tableOf(q)
+	if table == nil {
+		return nil
+	}
+
+	rows := reflect.New(reflect.SliceOf(table.Type))
+	sq := q.DB().NewSelect().
+		Conn(q.GetConn()).
+		Model(q.GetModel().Value()).
+		WhereFrom(q)
+	switch q.DB().Dialect().Name() {
+	case dialect.SQLite, dialect.MSSQL:
+	default:
+		sq = sq.For("UPDATE")
+	}
+	if err := sq.Scan(ctx, rows.Interface()); err != nil {
+		return fmt.Errorf("bunaudit: can't select %s rows: %w", table.Name, err)
+	}
+
+	h.pending.Store(q, uniqueRows(table, rows))
+	return nil
+}
+
+func (h *QueryHook) afterUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	v, ok := h.pending.LoadAndDelete(q)
+	if !ok {
+		return nil
+	}
+	before := v.(reflect.Value)
+	if before.Elem().Len() == 0 {
+		return nil
+	}
+	table := tableOf(q)
+
+	// Select the rows by the primary keys, because the update can change
+	// the columns used in the conditions of the query.
+	after := reflect.New(reflect.SliceOf(table.Type))
+	sq := q.DB().NewSelect().
+		Conn(q.GetConn()).
+		Model(before.Interface()).
+		WherePK()
+	if table.SoftDeleteField != nil {
+		sq = sq.WhereAllWithDeleted()
+	}
+	if scoper, ok := table.ZeroIface.(bun.DefaultScoper); ok {
+		for name := range scoper.DefaultScopes() {
+			sq = sq.WithoutScope(name)
+		}
+	}
+	if err := sq.Scan(ctx, after.Interface()); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("bunaudit: can't select %s rows: %w", table.Name, err)
+	}
+
+	afterByPK := make(map[string]reflect.Value)
+	forEachRow(after, func(strct reflect.Value) {
+		afterByPK[rowPK(table, strct)] = strct
+	})
+
+	var entries []Entry
+	forEachRow(before, func(strct reflect.Value) {
+		afterRow, ok := afterByPK[rowPK(table, strct)]
+		if !ok {
+			return
+		}
+		beforeValues, afterValues := diffRows(table, strct, afterRow)
+		if len(afterValues) > 0 {
+			entries = append(entries, h.newEntry(ctx, table, opUpdate, strct, beforeValues, afterValues))
+		}
+	})
+	return h.insert(ctx, q, entries)
+}
+
+func (h *QueryHook) afterDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	v, ok := h.pending.LoadAndDelete(q)
+	if !ok {
+		return nil
+	}
+	before := v.(reflect.Value)
+	table := tableOf(q)
+
+	var entries []Entry
+	forEachRow(before, func(strct reflect.Value) {
+		entries = append(entries, h.newEntry(ctx, table, opDelete, strct, rowValues(table, strct), nil))
+	})
+	return h.insert(ctx, q, entries)
+}
+
+func (h *QueryHook) newEntry(
+	ctx context.Context,
+	table *schema.Table,
+	op string,
+	strct reflect.Value,
+	before, after map[string]interface{},
+) Entry {
+	return Entry{
+		TableName: table.Name,
+		Operation: op,
+		PK:        rowPK(table, strct),
+		Actor:     h.actor(ctx),
+		Before:    before,
+		After:     after,
+	}
+}
+
+func (h *QueryHook) insert(ctx context.Context, q auditQuery, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if _, err := q.DB().NewInsert().
+		Conn(q.GetConn()).
+		Model(&entries).
+		ModelTableExpr("?", bun.Ident(h.table)).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("bunaudit: can't insert audit entries: %w", err)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// forEachRow calls fn for each struct of the model value,
+// which is a pointer to a struct or a slice.
+func forEachRow(v reflect.Value, fn func(strct reflect.Value)) {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		fn(v)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if elem := reflect.Indirect(v.Index(i)); elem.IsValid() {
+				fn(elem)
+			}
+		}
+	}
+}
+
+// uniqueRows removes the rows with the same primary key that are selected
+// when the query joins other tables.
+func uniqueRows(table *schema.Table, rows reflect.Value) reflect.Value {
+	slice := rows.Elem()
+	seen := make(map[string]struct{}, slice.Len())
+	n := 0
+	for i := 0; i < slice.Len(); i++ {
+		pk := rowPK(table, slice.Index(i))
+		if _, ok := seen[pk]; ok {
+			continue
+		}
+		seen[pk] = struct{}{}
+		slice.Index(n).Set(slice.Index(i))
+		n++
+	}
+	slice.SetLen(n)
+	return rows
+}
+
+func rowPK(table *schema.Table, strct reflect.Value) string {
+	pks := make([]string, len(table.PKs))
+	for i, f := range table.PKs {
+		pks[i] = fmt.Sprint(f.Value(strct).Interface())
+	}
+	return strings.Join(pks, ",")
+}
+
+func fieldValue(f *schema.Field, strct reflect.Value) interface{} {
+	if f.Sensitive {
+		return sensitiveValue
+	}
+	return f.Value(strct).Interface()
+}
+
+func rowValues(table *schema.Table, strct reflect.Value) map[string]interface{} {
+	m := make(map[string]interface{}, len(table.Fields))
+	for _, f := range table.Fields {
+		m[f.Name] = fieldValue(f, strct)
+	}
+	return m
+}
+
+// diffRows returns the values of the columns that differ.
+func diffRows(table *schema.Table, before, after reflect.Value) (map[string]interface{}, map[string]interface{}) {
+	beforeValues := make(map[string]interface{})
+	afterValues := make(map[string]interface{})
+	for _, f := range table.Fields {
+		if reflect.DeepEqual(f.Value(before).Interface(), f.Value(after).Interface()) {
+			continue
+		}
+		beforeValues[f.Name] = fieldValue(f, before)
+		afterValues[f.Name] = fieldValue(f, after)
+	}
+	return beforeValues, afterValues
+}
@@ -0,0 +1,22 @@
+package bunaudit
+
+import "context"
+
+// Option is a function that configures a QueryHook.
+type Option func(*QueryHook)
+
+// WithTable sets the table the audit entries are inserted into. The default is "audit_log".
+func WithTable(table string) Option {
+	return func(h *QueryHook) {
+		h.table = table
+	}
+}
+
+// WithActorFunc sets the function that returns the actor recorded in the audit entries,
+// for example, the user ID from the request context. By default, the actor set
+// with WithActor is used.
+func WithActorFunc(fn func(ctx context.Context) string) Option {
+	return func(h *QueryHook) {
+		h.actor = fn
+	}
+}
@@ -39,6 +39,13 @@ func WithWriter(w io.Writer) Option {
 	}
 }
 
+// WithRedaction configures how the values of the logged queries are redacted.
+func WithRedaction(redaction bun.Redaction) Option {
+	return func(h *QueryHook) {
+		h.redaction = redaction
+	}
+}
+
 // FromEnv configures the hook using the environment variable value.
 // For example, WithEnv("BUNDEBUG"):
 //   - BUNDEBUG=0 - disables the hook.
@@ -60,12 +67,13 @@ func FromEnv(keys ...string) Option {
 }
 
 type QueryHook struct {
-	enabled bool
-	verbose bool
-	writer  io.Writer
+	enabled   bool
+	verbose   bool
+	writer    io.Writer
+	redaction bun.Redaction
 }
 
-var _ bun.QueryHook = (*QueryHook)(nil)
+var _ bun.RedactionHook = (*QueryHook)(nil)
 
 func NewQueryHook(opts ...Option) *QueryHook {
 	h := &QueryHook{
@@ -104,7 +112,7 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 		now.Format(" 15:04:05.000 "),
 		formatOperation(event),
 		fmt.Sprintf(" %10s ", dur.Round(time.Microsecond)),
-		event.Query,
+		event.RedactedQuery(h.redaction),
 	}
 
 	if event.Err != nil {
@@ -118,6 +126,12 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 	fmt.Fprintln(h.writer, args...)
 }
 
+// Redaction returns the redaction of the logged queries, so the DB formats
+// the redacted queries before they are executed.
+func (h *QueryHook) Redaction() bun.Redaction {
+	return h.redaction
+}
+
 func formatOperation(event *bun.QueryEvent) string {
 	operation := event.Operation()
 	return operationColor(operation).Sprintf(" %-16s ", operation)
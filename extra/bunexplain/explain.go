@@ -0,0 +1,179 @@
+package bunexplain
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Handler is called with the plan of a slow query or the error returned by EXPLAIN.
+// It is called from a separate goroutine after the query is executed.
+type Handler func(ctx context.Context, event *bun.QueryEvent, plan string, err error)
+
+// QueryHook explains slow SELECT queries. EXPLAIN is executed without ANALYZE,
+// so it never executes the statement.
+//
+// EXPLAIN is executed asynchronously and doesn't delay the query. It uses the *sql.DB
+// that executed the query, for example, a replica. Queries executed in a transaction
+// or on a *sql.Conn are explained using the primary DB.
+type QueryHook struct {
+	threshold time.Duration
+	interval  time.Duration
+	timeout   time.Duration
+	logger    *slog.Logger
+	redaction bun.Redaction
+	handler   Handler
+
+	next int64 // unix nanoseconds
+}
+
+var _ bun.RedactionHook = (*QueryHook)(nil)
+
+// NewQueryHook initializes a new QueryHook with the given options.
+func NewQueryHook(opts ...Option) *QueryHook {
+	h := &QueryHook{
+		threshold: time.Second,
+		interval:  time.Minute,
+		timeout:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// BeforeQuery is called before a query is executed.
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery explains the query if it is a slow SELECT query.
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.DB == nil || event.Operation() != "SELECT" {
+		return
+	}
+	switch event.Err {
+	case nil, sql.ErrNoRows:
+	default:
+		return
+	}
+	dur := time.Since(event.StartTime)
+	if dur < h.threshold {
+		return
+	}
+
+	prefix := explainPrefix(event.DB.Dialect().Name())
+	if prefix == "" || !h.allow() {
+		return
+	}
+
+	var db *sql.DB
+	switch conn := event.Conn.(type) {
+	case *sql.DB:
+		db = conn
+	case bun.ReplicaConn:
+		db = conn.Replica()
+	default:
+		db = event.DB.DB
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		plan, err := h.explain(ctx, db, prefix+event.Query)
+		if h.handler != nil {
+			h.handler(ctx, event, plan, err)
+		} else {
+			h.log(ctx, event, dur, plan, err)
+		}
+	}()
+}
+
+// Redaction returns the redaction of the logged queries, so the DB formats
+// the redacted queries before they are executed.
+func (h *QueryHook) Redaction() bun.Redaction {
+	return h.redaction
+}
+
+// allow reports whether the interval since the last EXPLAIN has passed.
+func (h *QueryHook) allow() bool {
+	now := time.Now().UnixNano()
+	next := atomic.LoadInt64(&h.next)
+	if now < next {
+		return false
+	}
+	return atomic.CompareAndSwapInt64(&h.next, next, now+int64(h.interval))
+}
+
+func (h *QueryHook) explain(ctx context.Context, db *sql.DB, query string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	// The plan is in the last column: a single JSON document on PostgreSQL and MySQL
+	// and a row per step on SQLite.
+	var lines []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		lines = append(lines, string(values[len(values)-1]))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (h *QueryHook) log(
+	ctx context.Context, event *bun.QueryEvent, dur time.Duration, plan string, err error,
+) {
+	logger := h.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	attrs := []slog.Attr{
+		slog.String("query", event.RedactedQuery(h.redaction)),
+		slog.String("duration", dur.String()),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("explain_error", err))
+	} else {
+		attrs = append(attrs, slog.String("plan", plan))
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+}
+
+func explainPrefix(name dialect.Name) string {
+	switch name {
+	case dialect.PG:
+		return "EXPLAIN (FORMAT JSON) "
+	case dialect.MySQL:
+		return "EXPLAIN FORMAT=JSON "
+	case dialect.SQLite:
+		return "EXPLAIN QUERY PLAN "
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,59 @@
+package bunexplain
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Option is a function that configures a QueryHook.
+type Option func(*QueryHook)
+
+// WithSlowQueryThreshold sets the duration after which a SELECT query is explained.
+// The default is 1 second.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(h *QueryHook) {
+		h.threshold = threshold
+	}
+}
+
+// WithInterval sets the minimum interval between two EXPLAIN queries.
+// Slow queries that are executed within the interval are not explained.
+// The default is 1 minute.
+func WithInterval(interval time.Duration) Option {
+	return func(h *QueryHook) {
+		h.interval = interval
+	}
+}
+
+// WithTimeout sets the timeout of the EXPLAIN query. The default is 5 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *QueryHook) {
+		h.timeout = timeout
+	}
+}
+
+// WithLogger sets the *slog.Logger instance that is used by the default handler.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *QueryHook) {
+		h.logger = logger
+	}
+}
+
+// WithRedaction configures how the values of the logged queries
+// are redacted by the default handler.
+func WithRedaction(redaction bun.Redaction) Option {
+	return func(h *QueryHook) {
+		h.redaction = redaction
+	}
+}
+
+// WithHandler sets the function that is called with the plan of a slow query
+// or the error returned by EXPLAIN. By default, the query and the plan are logged
+// with slog at the warning level.
+func WithHandler(fn Handler) Option {
+	return func(h *QueryHook) {
+		h.handler = fn
+	}
+}
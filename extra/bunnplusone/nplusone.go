@@ -0,0 +1,211 @@
+package bunnplusone
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Report describes a query that was executed too many times from the same call site.
+type Report struct {
+	// Query is the query with the values replaced by placeholders.
+	Query string
+	// Count is the number of times the query was executed.
+	Count int
+
+	Func string
+	File string
+	Line int
+
+	// Model and Relation name the relation that can be loaded with
+	// SelectQuery.Relation instead, if the model was queried in the scope.
+	Model    string
+	Relation string
+}
+
+func (r *Report) String() string {
+	s := fmt.Sprintf("bun: N+1 query executed %d times at %s (%s:%d): %s",
+		r.Count, r.Func, r.File, r.Line, r.Query)
+	if r.Relation != "" {
+		s += fmt.Sprintf(" (consider eager-loading %s with Relation(%q))", r.Model, r.Relation)
+	}
+	return s
+}
+
+type scopeCtxKey struct{}
+
+// WithScope returns a context that groups the queries executed with it,
+// for example, the queries of an HTTP request. Queries executed
+// without a scope are not checked.
+func WithScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeCtxKey{}, newScope())
+}
+
+// QueryHook detects N+1 queries: the same query executed in a loop
+// instead of loading a relation with SelectQuery.Relation. It is meant
+// for development and tests.
+//
+// Queries are grouped by the query template and the call site outside of bun.
+type QueryHook struct {
+	maxQueries int
+	panic      bool
+	logger     *slog.Logger
+	handler    func(ctx context.Context, report *Report)
+}
+
+var _ bun.RedactionHook = (*QueryHook)(nil)
+
+// NewQueryHook initializes a new QueryHook with the given options.
+func NewQueryHook(opts ...Option) *QueryHook {
+	h := &QueryHook{
+		maxQueries: 5,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// BeforeQuery is called before a query is executed.
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery counts the query in the scope and reports it when
+// the number of executions exceeds the limit.
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	s, _ := ctx.Value(scopeCtxKey{}).(*scope)
+	if s == nil {
+		return
+	}
+
+	var table *schema.Table
+	if tm, ok := event.Model.(bun.TableModel); ok {
+		table = tm.Table()
+	}
+
+	// The query with placeholders is the QueryTemplate of raw queries and the template
+	// of query builders that bun formats before executing the query, so reading it
+	// doesn't format the query again.
+	fn, file, line := funcFileLine(event)
+	key := queryKey{
+		query: event.RedactedQuery(bun.RedactValues),
+		file:  file,
+		line:  line,
+	}
+
+	count, seen := s.add(key, table)
+	if count != h.maxQueries+1 {
+		return
+	}
+
+	report := &Report{
+		Query: key.query,
+		Count: count,
+		Func:  fn,
+		File:  file,
+		Line:  line,
+	}
+	if table != nil {
+		report.Model, report.Relation = suggestRelation(seen, table)
+	}
+	h.report(ctx, report)
+}
+
+// Redaction requests the queries with the values replaced by placeholders,
+// which are used to group the queries.
+func (h *QueryHook) Redaction() bun.Redaction {
+	return bun.RedactValues
+}
+
+func (h *QueryHook) report(ctx context.Context, report *Report) {
+	switch {
+	case h.panic:
+		panic(report.String())
+	case h.handler != nil:
+		h.handler(ctx, report)
+	default:
+		logger := h.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.WarnContext(ctx, report.String())
+	}
+}
+
+// suggestRelation returns the model and the relation that joins the table
+// to one of the tables queried in the scope.
+func suggestRelation(tables []*schema.Table, table *schema.Table) (string, string) {
+	for _, t := range tables {
+		names := make([]string, 0, len(t.Relations))
+		for name, rel := range t.Relations {
+			if rel.JoinTable == table {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			return t.TypeName, names[0]
+		}
+	}
+	return "", ""
+}
+
+//------------------------------------------------------------------------------
+
+type queryKey struct {
+	query string
+	file  string
+	line  int
+}
+
+type scope struct {
+	mu     sync.Mutex
+	counts map[queryKey]int
+	tables []*schema.Table
+}
+
+func newScope() *scope {
+	return &scope{
+		counts: make(map[queryKey]int),
+	}
+}
+
+// add counts the query and returns the number of executions
+// and the tables queried in the scope before.
+func (s *scope) add(key queryKey, table *schema.Table) (int, []*schema.Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[key]++
+	seen := s.tables
+	if table != nil && !containsTable(s.tables, table) {
+		s.tables = append(s.tables, table)
+	}
+	return s.counts[key], seen
+}
+
+func containsTable(tables []*schema.Table, table *schema.Table) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// funcFileLine returns the function name, file, and line of the query caller.
+func funcFileLine(event *bun.QueryEvent) (string, string, int) {
+	caller := event.Caller()
+	fn := caller.Function
+	if ind := strings.LastIndexByte(fn, '/'); ind != -1 {
+		fn = fn[ind+1:]
+	}
+	return fn, caller.File, caller.Line
+}
@@ -0,0 +1,40 @@
+package bunnplusone
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Option is a function that configures a QueryHook.
+type Option func(*QueryHook)
+
+// WithMaxQueries sets the number of times a query can be executed from the same
+// call site in a scope before it is reported. The default is 5.
+func WithMaxQueries(n int) Option {
+	return func(h *QueryHook) {
+		h.maxQueries = n
+	}
+}
+
+// WithPanic configures the hook to panic with the report instead of logging it.
+// It is useful in tests.
+func WithPanic(on bool) Option {
+	return func(h *QueryHook) {
+		h.panic = on
+	}
+}
+
+// WithLogger sets the *slog.Logger instance that is used to log reports.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *QueryHook) {
+		h.logger = logger
+	}
+}
+
+// WithHandler sets the function that is called with the reports
+// instead of logging them.
+func WithHandler(fn func(ctx context.Context, report *Report)) Option {
+	return func(h *QueryHook) {
+		h.handler = fn
+	}
+}
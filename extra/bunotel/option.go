@@ -5,6 +5,8 @@ import (
 	"go.opentelemetry.io/otel/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/uptrace/bun"
 )
 
 type Option func(h *QueryHook)
@@ -33,6 +35,15 @@ func WithFormattedQueries(format bool) Option {
 	}
 }
 
+// WithRedaction configures how the values of the formatted queries are redacted.
+// It has no effect unless WithFormattedQueries is enabled,
+// because unformatted queries contain placeholders instead of values.
+func WithRedaction(redaction bun.Redaction) Option {
+	return func(h *QueryHook) {
+		h.redaction = redaction
+	}
+}
+
 // WithTracerProvider returns an Option to use the TracerProvider when
 // creating a Tracer.
 func WithTracerProvider(tp trace.TracerProvider) Option {
@@ -3,7 +3,6 @@ package bunotel
 import (
 	"context"
 	"database/sql"
-	"runtime"
 	"strings"
 	"time"
 
@@ -23,12 +22,16 @@ import (
 type QueryHook struct {
 	attrs          []attribute.KeyValue
 	formatQueries  bool
+	redaction      bun.Redaction
 	tracer         trace.Tracer
 	meter          metric.Meter
 	queryHistogram metric.Int64Histogram
 }
 
-var _ bun.QueryHook = (*QueryHook)(nil)
+var (
+	_ bun.QueryHook = (*QueryHook)(nil)
+	_ bun.TxHook    = (*QueryHook)(nil)
+)
 
 func NewQueryHook(opts ...Option) *QueryHook {
 	h := new(QueryHook)
@@ -89,7 +92,7 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 	defer span.End()
 
 	query := h.eventQuery(event)
-	fn, file, line := funcFileLine("github.com/uptrace/bun")
+	fn, file, line := funcFileLine(event)
 
 	attrs := make([]attribute.KeyValue, 0, 10)
 	attrs = append(attrs, h.attrs...)
@@ -104,7 +107,9 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 	if sys := dbSystem(event.DB); sys.Valid() {
 		attrs = append(attrs, sys)
 	}
-	if event.Result != nil {
+	if event.RowsScanned > 0 {
+		attrs = append(attrs, attribute.Int("db.rows_returned", event.RowsScanned))
+	} else if event.Result != nil {
 		if n, _ := event.Result.RowsAffected(); n > 0 {
 			attrs = append(attrs, attribute.Int64("db.rows_affected", n))
 		}
@@ -121,30 +126,81 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 	span.SetAttributes(attrs...)
 }
 
-func funcFileLine(pkg string) (string, string, int) {
-	const depth = 16
-	var pcs [depth]uintptr
-	n := runtime.Callers(3, pcs[:])
-	ff := runtime.CallersFrames(pcs[:n])
-
-	var fn, file string
-	var line int
-	for {
-		f, ok := ff.Next()
-		if !ok {
-			break
-		}
-		fn, file, line = f.Function, f.File, f.Line
-		if !strings.Contains(fn, pkg) {
-			break
-		}
+// Redaction returns the redaction of the formatted queries, so the DB formats
+// the redacted queries before they are executed.
+func (h *QueryHook) Redaction() bun.Redaction {
+	if !h.formatQueries {
+		return bun.RedactNone
+	}
+	return h.redaction
+}
+
+// BeforeTx starts a span that is used as the parent of the queries executed
+// in the transaction started by RunInTx.
+func (h *QueryHook) BeforeTx(ctx context.Context, event *bun.TxEvent) context.Context {
+	name := "TRANSACTION"
+	if event.Savepoint != "" {
+		name = "SAVEPOINT"
+	}
+	ctx, _ = h.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx
+}
+
+func (h *QueryHook) AfterTx(ctx context.Context, event *bun.TxEvent) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	attrs := make([]attribute.KeyValue, 0, len(h.attrs)+4)
+	attrs = append(attrs, h.attrs...)
+	attrs = append(attrs, attribute.String("db.transaction.outcome", strings.ToLower(event.Operation)))
+	if sys := dbSystem(event.DB); sys.Valid() {
+		attrs = append(attrs, sys)
+	}
+	if event.Savepoint != "" {
+		attrs = append(attrs, attribute.String("db.savepoint", event.Savepoint))
+	}
+	if event.TxAttempt > 0 {
+		attrs = append(attrs, attribute.Int("db.transaction.attempt", event.TxAttempt))
+	}
+
+	switch event.Err {
+	case nil, sql.ErrTxDone:
+		// ignore
+	default:
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
 	}
 
+	span.SetAttributes(attrs...)
+}
+
+// TraceComment returns the W3C traceparent of the span in the context.
+// Use it with bun.WithCommenter to correlate the queries in the database logs
+// with the traces:
+//
+//	db := bun.NewDB(sqldb, dialect, bun.WithCommenter(bunotel.TraceComment))
+func TraceComment(ctx context.Context) map[string]string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return map[string]string{
+		"traceparent": "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() +
+			"-" + sc.TraceFlags().String(),
+	}
+}
+
+// funcFileLine returns the function name, file, and line of the query caller.
+func funcFileLine(event *bun.QueryEvent) (string, string, int) {
+	caller := event.Caller()
+	fn := caller.Function
 	if ind := strings.LastIndexByte(fn, '/'); ind != -1 {
 		fn = fn[ind+1:]
 	}
-
-	return fn, file, line
+	return fn, caller.File, caller.Line
 }
 
 func (h *QueryHook) eventQuery(event *bun.QueryEvent) string {
@@ -154,7 +210,7 @@ func (h *QueryHook) eventQuery(event *bun.QueryEvent) string {
 	var query string
 
 	if h.formatQueries && len(event.Query) <= softQueryLimit {
-		query = event.Query
+		query = event.RedactedQuery(h.redaction)
 	} else {
 		query = unformattedQuery(event)
 	}
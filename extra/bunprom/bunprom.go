@@ -0,0 +1,168 @@
+package bunprom
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	otherLabel   = "other"
+	unknownLabel = "unknown"
+)
+
+type QueryHook struct {
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+
+	tables     *labelLimiter
+	errorCodes *labelLimiter
+}
+
+var _ bun.QueryHook = (*QueryHook)(nil)
+
+// NewQueryHook creates a new bun.QueryHook that exports the duration of queries
+// labelled by the operation and table and the number of failed queries
+// labelled by the operation and SQLSTATE error code.
+//
+// The metrics are registered with the configured registerer. Hooks that share
+// a registerer share the metrics, so use WithConstLabels to tell databases apart.
+func NewQueryHook(opts ...Option) *QueryHook {
+	c := newConfig(opts)
+
+	h := &QueryHook{
+		tables:     newLabelLimiter(c.maxTables),
+		errorCodes: newLabelLimiter(c.maxErrorCodes),
+	}
+	h.queryDuration = register(c.registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Name:        "query_duration_seconds",
+			Help:        "Duration of processed queries.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		},
+		[]string{"operation", "table"},
+	))
+	h.queryErrors = register(c.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Name:        "query_errors_total",
+			Help:        "Number of failed queries.",
+			ConstLabels: c.constLabels,
+		},
+		[]string{"operation", "sqlstate"},
+	))
+	return h
+}
+
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	operation := event.Operation()
+
+	var table string
+	if event.IQuery != nil {
+		table = event.IQuery.GetTableName()
+	}
+	table = h.tables.value(table)
+
+	dur := time.Since(event.StartTime)
+	h.queryDuration.WithLabelValues(operation, table).Observe(dur.Seconds())
+
+	switch event.Err {
+	case nil, sql.ErrNoRows:
+		// ignore
+	default:
+		code := h.errorCodes.value(sqlState(event.Err))
+		h.queryErrors.WithLabelValues(operation, code).Inc()
+	}
+}
+
+// sqlState returns the SQLSTATE error code of the error
+// if the driver reports it and "unknown" otherwise.
+func sqlState(err error) string {
+	// pgx
+	var stater interface{ SQLState() string }
+	if errors.As(err, &stater) {
+		if code := stater.SQLState(); code != "" {
+			return code
+		}
+	}
+
+	// pgdriver
+	var fielder interface{ Field(byte) string }
+	if errors.As(err, &fielder) {
+		if code := fielder.Field('C'); code != "" {
+			return code
+		}
+	}
+
+	return unknownLabel
+}
+
+//------------------------------------------------------------------------------
+
+// labelLimiter limits the number of distinct values of a label.
+type labelLimiter struct {
+	max int
+
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+func newLabelLimiter(max int) *labelLimiter {
+	return &labelLimiter{
+		max:    max,
+		values: make(map[string]struct{}),
+	}
+}
+
+// value returns the label value or "other" when the limit is reached.
+func (l *labelLimiter) value(v string) string {
+	if l.max <= 0 {
+		return v
+	}
+
+	l.mu.RLock()
+	_, ok := l.values[v]
+	l.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.values[v]; ok {
+		return v
+	}
+	if len(l.values) >= l.max {
+		return otherLabel
+	}
+	l.values[v] = struct{}{}
+	return v
+}
+
+// register registers the collector or returns the collector
+// that is already registered with the same descriptor.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,157 @@
+package bunprom
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/uptrace/bun"
+)
+
+type pgError struct {
+	code string
+}
+
+func (e pgError) Error() string    { return "ERROR: " + e.code }
+func (e pgError) SQLState() string { return e.code }
+
+func TestQueryHook(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	hook := NewQueryHook(WithRegisterer(reg), WithMaxErrorCodes(1))
+
+	events := []*bun.QueryEvent{
+		{Query: "SELECT 1"},
+		{Query: "SELECT 1", Err: sql.ErrNoRows},
+		{Query: "INSERT INTO users", Err: fmt.Errorf("insert: %w", pgError{code: "23505"})},
+		{Query: "INSERT INTO users", Err: pgError{code: "23503"}},
+		{Query: "UPDATE users", Err: errors.New("oops")},
+	}
+	for _, event := range events {
+		event.StartTime = time.Now()
+		hook.AfterQuery(context.Background(), event)
+	}
+
+	families := gather(t, reg)
+
+	durations := families["bun_query_duration_seconds"]
+	if durations == nil {
+		t.Fatal("bun_query_duration_seconds is not reported")
+	}
+	counts := make(map[string]uint64)
+	for _, m := range durations.Metric {
+		counts[labelValue(m, "operation")] += m.GetHistogram().GetSampleCount()
+	}
+	if want := map[string]uint64{"SELECT": 2, "INSERT": 2, "UPDATE": 1}; !equalCounts(counts, want) {
+		t.Fatalf("got %v, wanted %v", counts, want)
+	}
+
+	errs := families["bun_query_errors_total"]
+	if errs == nil {
+		t.Fatal("bun_query_errors_total is not reported")
+	}
+	codes := make(map[string]uint64)
+	for _, m := range errs.Metric {
+		codes[labelValue(m, "sqlstate")] += uint64(m.GetCounter().GetValue())
+	}
+	if want := map[string]uint64{"23505": 1, "other": 2}; !equalCounts(codes, want) {
+		t.Fatalf("got %v, wanted %v", codes, want)
+	}
+
+	// Hooks that share a registerer share the metrics.
+	NewQueryHook(WithRegisterer(reg))
+}
+
+func TestLabelLimiter(t *testing.T) {
+	l := newLabelLimiter(2)
+	for _, v := range []string{"users", "orders", "users"} {
+		if got := l.value(v); got != v {
+			t.Fatalf("got %q, wanted %q", got, v)
+		}
+	}
+	if got := l.value("items"); got != otherLabel {
+		t.Fatalf("got %q, wanted %q", got, otherLabel)
+	}
+
+	l = newLabelLimiter(0)
+	if got := l.value("items"); got != "items" {
+		t.Fatalf("got %q, wanted %q", got, "items")
+	}
+}
+
+type nopConnector struct{}
+
+func (nopConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func (nopConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestStatsCollector(t *testing.T) {
+	db := sql.OpenDB(nopConnector{})
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewStatsCollector(db,
+		WithNamespace("app"),
+		WithConstLabels(prometheus.Labels{"db_name": "test"})))
+
+	families := gather(t, reg)
+
+	family := families["app_db_max_open_connections"]
+	if family == nil {
+		t.Fatal("app_db_max_open_connections is not reported")
+	}
+	m := family.Metric[0]
+	if got := m.GetGauge().GetValue(); got != 7 {
+		t.Fatalf("got %v, wanted 7", got)
+	}
+	if got := labelValue(m, "db_name"); got != "test" {
+		t.Fatalf("got %q, wanted %q", got, "test")
+	}
+	if families["app_db_wait_duration_seconds_total"] == nil {
+		t.Fatal("app_db_wait_duration_seconds_total is not reported")
+	}
+}
+
+func gather(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		m[family.GetName()] = family
+	}
+	return m
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, label := range m.Label {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+func equalCounts(got, want map[string]uint64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if got[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package bunprom
+
+import "github.com/prometheus/client_golang/prometheus"
+
+type config struct {
+	namespace     string
+	constLabels   prometheus.Labels
+	registerer    prometheus.Registerer
+	buckets       []float64
+	maxTables     int
+	maxErrorCodes int
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		namespace:     "bun",
+		registerer:    prometheus.DefaultRegisterer,
+		buckets:       prometheus.DefBuckets,
+		maxTables:     100,
+		maxErrorCodes: 50,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type Option func(c *config)
+
+// WithNamespace sets the namespace of the metric names. The default is "bun".
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels adds the labels to all metrics, for example, the database name.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithRegisterer configures the registerer that is used to register the metrics
+// of the query hook. The default is prometheus.DefaultRegisterer.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(c *config) {
+		if reg != nil {
+			c.registerer = reg
+		}
+	}
+}
+
+// WithDurationBuckets configures the buckets of the query duration histogram in seconds.
+func WithDurationBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// WithMaxTables limits the number of distinct values of the table label.
+// Queries on other tables are reported with the "other" table.
+// Zero or a negative number disables the limit. The default is 100.
+func WithMaxTables(n int) Option {
+	return func(c *config) {
+		c.maxTables = n
+	}
+}
+
+// WithMaxErrorCodes limits the number of distinct values of the sqlstate label.
+// Other errors are reported with the "other" code.
+// Zero or a negative number disables the limit. The default is 50.
+func WithMaxErrorCodes(n int) Option {
+	return func(c *config) {
+		c.maxErrorCodes = n
+	}
+}
@@ -0,0 +1,100 @@
+package bunprom
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsCollector is a prometheus.Collector that exports the connection pool
+// statistics returned by sql.DB.Stats.
+type StatsCollector struct {
+	db *sql.DB
+
+	maxOpenConnections *prometheus.Desc
+
+	openConnections  *prometheus.Desc
+	inUseConnections *prometheus.Desc
+	idleConnections  *prometheus.Desc
+
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*StatsCollector)(nil)
+
+// NewStatsCollector creates a collector for the connection pool of the database,
+// for example, bun.DB.DB. It uses the namespace and const labels options
+// and must be registered by the caller:
+//
+//	prometheus.MustRegister(bunprom.NewStatsCollector(db.DB,
+//		bunprom.WithConstLabels(prometheus.Labels{"db_name": "mydb"})))
+func NewStatsCollector(db *sql.DB, opts ...Option) *StatsCollector {
+	c := newConfig(opts)
+
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(c.namespace, "db", name), help, nil, c.constLabels)
+	}
+
+	return &StatsCollector{
+		db: db,
+
+		maxOpenConnections: desc("max_open_connections",
+			"Maximum number of open connections to the database."),
+
+		openConnections: desc("open_connections",
+			"The number of established connections both in use and idle."),
+		inUseConnections: desc("in_use_connections",
+			"The number of connections currently in use."),
+		idleConnections: desc("idle_connections",
+			"The number of idle connections."),
+
+		waitCount: desc("wait_count_total",
+			"The total number of connections waited for."),
+		waitDuration: desc("wait_duration_seconds_total",
+			"The total time blocked waiting for a new connection."),
+		maxIdleClosed: desc("max_idle_closed_total",
+			"The total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total",
+			"The total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total",
+			"The total number of connections closed due to SetConnMaxLifetime."),
+	}
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpenConnections
+	ch <- c.openConnections
+	ch <- c.inUseConnections
+	ch <- c.idleConnections
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+
+	gauge(c.maxOpenConnections, float64(stats.MaxOpenConnections))
+	gauge(c.openConnections, float64(stats.OpenConnections))
+	gauge(c.inUseConnections, float64(stats.InUse))
+	gauge(c.idleConnections, float64(stats.Idle))
+	counter(c.waitCount, float64(stats.WaitCount))
+	counter(c.waitDuration, stats.WaitDuration.Seconds())
+	counter(c.maxIdleClosed, float64(stats.MaxIdleClosed))
+	counter(c.maxIdleTimeClosed, float64(stats.MaxIdleTimeClosed))
+	counter(c.maxLifetimeClosed, float64(stats.MaxLifetimeClosed))
+}
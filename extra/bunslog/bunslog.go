@@ -51,6 +51,14 @@ func WithSlowQueryThreshold(threshold time.Duration) Option {
 	}
 }
 
+// WithRedaction configures how the values of the logged queries are redacted
+// by the default log format.
+func WithRedaction(redaction bun.Redaction) Option {
+	return func(h *QueryHook) {
+		h.redaction = redaction
+	}
+}
+
 // WithLogFormat sets the custom format for slog output.
 func WithLogFormat(f logFormat) Option {
 	return func(h *QueryHook) {
@@ -69,6 +77,7 @@ type QueryHook struct {
 	errorLogLevel      slog.Level
 	slowQueryThreshold time.Duration
 	logFormat          func(event *bun.QueryEvent) []slog.Attr
+	redaction          bun.Redaction
 	now                func() time.Time
 }
 
@@ -93,7 +102,7 @@ func NewQueryHook(opts ...Option) *QueryHook {
 			return []slog.Attr{
 				slog.Any("error", event.Err),
 				slog.String("operation", event.Operation()),
-				slog.String("query", event.Query),
+				slog.String("query", event.RedactedQuery(h.redaction)),
 				slog.String("duration", duration.String()),
 			}
 		}
@@ -129,6 +138,12 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 	slog.LogAttrs(ctx, level, "", attrs...)
 }
 
+// Redaction returns the redaction of the logged queries, so the DB formats
+// the redacted queries before they are executed.
+func (h *QueryHook) Redaction() bun.Redaction {
+	return h.redaction
+}
+
 var (
 	_ bun.QueryHook = (*QueryHook)(nil)
 )
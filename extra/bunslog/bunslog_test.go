@@ -198,4 +198,27 @@ func TestAfterQuery(t *testing.T) {
 			t.Errorf("unexpected logging want=%+v but got=%+v", expect, result)
 		}
 	})
+
+	t.Run("redaction", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		hook := NewQueryHook(WithLogger(logger), WithRedaction(bun.RedactValues))
+		event := &bun.QueryEvent{
+			Query:         "SELECT * FROM `users` WHERE `password` = 'secret'",
+			QueryTemplate: "SELECT * FROM `users` WHERE `password` = ?",
+			QueryArgs:     []interface{}{"secret"},
+			StartTime:     time.Now(),
+		}
+		hook.AfterQuery(context.Background(), event)
+
+		var result Record
+		if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+			t.Fatalf("failed to unmarshal JSON: %v", err)
+		}
+
+		if result.Query != event.QueryTemplate {
+			t.Errorf("unexpected query want=%q but got=%q", event.QueryTemplate, result.Query)
+		}
+	})
 }
@@ -15,7 +15,7 @@ import (
 	"github.com/uptrace/bun"
 )
 
-var _ bun.QueryHook = (*QueryHook)(nil)
+var _ bun.RedactionHook = (*QueryHook)(nil)
 
 // Option is a function that configures a QueryHook.
 type Option func(*QueryHook)
@@ -55,6 +55,14 @@ func WithSlowQueryThreshold(threshold time.Duration) Option {
 	}
 }
 
+// WithRedaction configures how the values of the logged queries are redacted
+// by the default log format.
+func WithRedaction(redaction bun.Redaction) Option {
+	return func(h *QueryHook) {
+		h.redaction = redaction
+	}
+}
+
 // WithLogFormat sets the custom format for slog output.
 func WithLogFormat(f LogFormatFn) Option {
 	return func(h *QueryHook) {
@@ -73,6 +81,7 @@ type QueryHook struct {
 	errorLogLevel      zerolog.Level
 	slowQueryThreshold time.Duration
 	logFormat          LogFormatFn
+	redaction          bun.Redaction
 	now                func() time.Time
 }
 
@@ -97,7 +106,7 @@ func NewQueryHook(opts ...Option) *QueryHook {
 			return zerevent.
 				Ctx(ctx).
 				Err(event.Err).
-				Str("query", event.Query).
+				Str("query", event.RedactedQuery(h.redaction)).
 				Str("operation", event.Operation()).
 				Str("duration", duration.String())
 		}
@@ -131,3 +140,9 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 
 	h.logFormat(ctx, event, l.WithLevel(level)).Send()
 }
+
+// Redaction returns the redaction of the logged queries, so the DB formats
+// the redacted queries before they are executed.
+func (h *QueryHook) Redaction() bun.Redaction {
+	return h.redaction
+}
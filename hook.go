@@ -3,11 +3,13 @@ package bun
 import (
 	"context"
 	"database/sql"
+	"runtime"
 	"strings"
 	"sync/atomic"
 	"time"
 	"unicode"
 
+	"github.com/uptrace/bun/internal"
 	"github.com/uptrace/bun/schema"
 )
 
@@ -25,7 +27,90 @@ type QueryEvent struct {
 	Result    sql.Result
 	Err       error
 
+	// RowsScanned is the number of rows scanned into the model.
+	RowsScanned int
+
+	// Conn is the connection the query is executed on: *sql.DB, *sql.Conn, *sql.Tx,
+	// ReplicaConn, or the connection passed to the query builder. Compare it to tell apart
+	// the queries of different transactions and connections.
+	Conn IConn
+	// InTx reports whether the query is executed in a transaction.
+	InTx bool
+
+	// TxAttempt is the attempt of the transaction retried by RunInTx, starting from 1.
+	// It is 0 when the query is not executed by RunInTx with WithTxRetry.
+	TxAttempt int
+
 	Stash map[interface{}]interface{}
+
+	fmter  schema.Formatter
+	caller *runtime.Frame
+
+	// sensitiveQuery and valuesQuery are the redacted variants of the query
+	// formatted before it is executed for the redactions requested by the hooks.
+	redactions     internal.Flag
+	sensitiveQuery string
+	valuesQuery    string
+}
+
+// Caller returns the frame of the function outside of Bun that executed the query.
+// It is computed on the first call and must be called from the query hooks,
+// because it inspects the stack of the goroutine that executes the query.
+func (e *QueryEvent) Caller() runtime.Frame {
+	if e.caller == nil {
+		frame := callerFrame()
+		e.caller = &frame
+	}
+	return *e.caller
+}
+
+// callerFrame skips the frames of the query hook and Bun
+// and returns the first frame of the code that executed the query.
+func callerFrame() runtime.Frame {
+	const depth = 32
+	var pcs [depth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	ff := runtime.CallersFrames(pcs[:n])
+
+	var frame runtime.Frame
+	var inBun bool
+	for {
+		f, more := ff.Next()
+		if isBunFunc(f.Function) {
+			inBun = true
+		} else if inBun {
+			return f
+		}
+		frame = f
+		if !more {
+			break
+		}
+	}
+	return frame
+}
+
+func isBunFunc(fn string) bool {
+	for _, prefix := range []string{
+		"github.com/uptrace/bun.",
+		"github.com/uptrace/bun/schema.",
+		"github.com/uptrace/bun/internal.",
+		"github.com/uptrace/bun/extra/",
+		"github.com/uptrace/bun/migrate.",
+		"github.com/uptrace/bun/dbfixture.",
+	} {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTx(conn IConn) bool {
+	_, ok := conn.(interface {
+		Commit() error
+		Rollback() error
+	})
+	return ok
 }
 
 func (e *QueryEvent) Operation() string {
@@ -52,6 +137,34 @@ type QueryHook interface {
 	AfterQuery(context.Context, *QueryEvent)
 }
 
+// TxEvent describes a transaction or a savepoint started with BeginTx or RunInTx.
+type TxEvent struct {
+	DB *DB
+
+	// Savepoint is the name of the savepoint for transactions started with Tx.BeginTx.
+	Savepoint string
+	// Operation is the operation that ended the transaction: BEGIN if the transaction
+	// failed to start, COMMIT, or ROLLBACK.
+	Operation string
+	// TxAttempt is the attempt of the transaction retried by RunInTx, see QueryEvent.TxAttempt.
+	TxAttempt int
+
+	StartTime time.Time
+	Err       error
+
+	Stash map[interface{}]interface{}
+
+	ended uint32
+}
+
+// TxHook can be implemented by a QueryHook to be notified when transactions start and end.
+// BeforeTx is called before BEGIN and the returned context is used for the queries
+// of the transaction started by RunInTx. AfterTx is called once after COMMIT or ROLLBACK.
+type TxHook interface {
+	BeforeTx(context.Context, *TxEvent) context.Context
+	AfterTx(context.Context, *TxEvent)
+}
+
 func (db *DB) beforeQuery(
 	ctx context.Context,
 	iquery Query,
@@ -59,6 +172,7 @@ func (db *DB) beforeQuery(
 	queryArgs []interface{},
 	query string,
 	model Model,
+	conn IConn,
 ) (context.Context, *QueryEvent) {
 	atomic.AddUint32(&db.stats.Queries, 1)
 
@@ -76,7 +190,16 @@ func (db *DB) beforeQuery(
 		QueryTemplate: queryTemplate,
 		QueryArgs:     queryArgs,
 
+		Conn: conn,
+		InTx: isTx(conn),
+
 		StartTime: time.Now(),
+		TxAttempt: txAttempt(ctx),
+
+		fmter: db.formatter(ctx),
+	}
+	if iquery != nil && db.redactions != 0 {
+		event.formatRedacted(db.redactions)
 	}
 
 	for _, hook := range db.queryHooks {
@@ -114,3 +237,39 @@ func (db *DB) afterQueryFromIndex(ctx context.Context, event *QueryEvent, hookIn
 		db.queryHooks[hookIndex].AfterQuery(ctx, event)
 	}
 }
+
+func (db *DB) beforeTx(ctx context.Context, savepoint string) (context.Context, *TxEvent) {
+	if len(db.queryHooks) == 0 {
+		return ctx, nil
+	}
+
+	event := &TxEvent{
+		DB:        db,
+		Savepoint: savepoint,
+		TxAttempt: txAttempt(ctx),
+		StartTime: time.Now(),
+	}
+
+	for _, hook := range db.queryHooks {
+		if hook, ok := hook.(TxHook); ok {
+			ctx = hook.BeforeTx(ctx, event)
+		}
+	}
+
+	return ctx, event
+}
+
+func (db *DB) afterTx(ctx context.Context, event *TxEvent, operation string, err error) {
+	if event == nil || !atomic.CompareAndSwapUint32(&event.ended, 0, 1) {
+		return
+	}
+
+	event.Operation = operation
+	event.Err = err
+
+	for i := len(db.queryHooks) - 1; i >= 0; i-- {
+		if hook, ok := db.queryHooks[i].(TxHook); ok {
+			hook.AfterTx(ctx, event)
+		}
+	}
+}
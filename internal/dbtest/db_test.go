@@ -27,7 +27,7 @@ import (
 	"github.com/uptrace/bun/schema"
 
 	_ "github.com/denisenkom/go-mssqldb"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/require"
 )
@@ -243,6 +243,13 @@ func funcName(x interface{}) string {
 	return s
 }
 
+func TestMySQLTxRetryable(t *testing.T) {
+	d := mysqldialect.New()
+	require.True(t, d.IsTxRetryable(&mysql.MySQLError{Number: 1213}))
+	require.True(t, d.IsTxRetryable(errors.Join(errors.New("commit"), &mysql.MySQLError{Number: 1205})))
+	require.False(t, d.IsTxRetryable(&mysql.MySQLError{Number: 1062}))
+}
+
 func TestDB(t *testing.T) {
 	type Test struct {
 		run func(t *testing.T, db *bun.DB)
@@ -270,6 +277,7 @@ func TestDB(t *testing.T) {
 		{testScanSingleRowByRow},
 		{testScanRows},
 		{testRunInTx},
+		{testRunInTxRetry},
 		{testJSONInterface},
 		{testJSONValuer},
 		{testSelectBool},
@@ -298,6 +306,13 @@ func TestDB(t *testing.T) {
 		{testRunInTxAndSavepoint},
 		{testDriverValuerReturnsItself},
 		{testNoPanicWhenReturningNullColumns},
+		{testSelectJoinSubquery},
+		{testOptimisticLocking},
+		{testTimestamps},
+		{testSchemaPerTenant},
+		{testExplain},
+		{testSelectCache},
+		{testSaveRelationsInTx},
 	}
 
 	testEachDB(t, func(t *testing.T, dbName string, db *bun.DB) {
@@ -734,6 +749,90 @@ func testRunInTx(t *testing.T, db *bun.DB) {
 	require.Equal(t, 1, count)
 }
 
+var errRetryableTx = errors.New("retryable")
+
+// retryDialect is an SQLite dialect that treats errRetryableTx as retryable.
+type retryDialect struct {
+	*sqlitedialect.Dialect
+}
+
+func (retryDialect) IsTxRetryable(err error) bool {
+	return errors.Is(err, errRetryableTx)
+}
+
+func testRunInTxRetry(t *testing.T, db *bun.DB) {
+	var d schema.Dialect
+	var conflict func(ctx context.Context, tx bun.Tx) error
+	switch db.Dialect().Name() {
+	case dialect.PG:
+		d = db.Dialect()
+		conflict = func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.ExecContext(ctx,
+				"DO $$ BEGIN RAISE EXCEPTION 'conflict' USING ERRCODE = '40001'; END $$")
+			return err
+		}
+	case dialect.SQLite:
+		d = retryDialect{sqlitedialect.New()}
+		conflict = func(ctx context.Context, tx bun.Tx) error {
+			return errRetryableTx
+		}
+	default:
+		t.Skip()
+	}
+
+	var attempts []int
+	hook := &queryHook{}
+	hook.beforeQuery = func(ctx context.Context, event *bun.QueryEvent) context.Context {
+		if event.Query == "BEGIN" {
+			attempts = append(attempts, event.TxAttempt)
+		}
+		return ctx
+	}
+
+	retryDB := bun.NewDB(db.DB, d, bun.WithTxRetry(5, func(attempt int) time.Duration {
+		return time.Millisecond
+	}))
+	retryDB.AddQueryHook(hook)
+
+	var calls int
+	err := retryDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		calls++
+		if calls < 3 {
+			return conflict(ctx, tx)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	require.Equal(t, []int{1, 2, 3}, attempts)
+
+	calls = 0
+	err = retryDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		calls++
+		return errors.New("not retryable")
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	calls = 0
+	err = bun.NewDB(db.DB, d, bun.WithTxRetry(2, nil)).RunInTx(
+		ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			calls++
+			return conflict(ctx, tx)
+		})
+	require.Error(t, err)
+	require.Equal(t, 2, calls)
+
+	// Transactions are not retried without WithTxRetry.
+	calls = 0
+	err = bun.NewDB(db.DB, d).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		calls++
+		return conflict(ctx, tx)
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+}
+
 func testJSONSpecialChars(t *testing.T, db *bun.DB) {
 	type Model struct {
 		ID    int                    `bun:",pk,autoincrement"`
@@ -1775,6 +1874,76 @@ func testNoPanicWhenReturningNullColumns(t *testing.T, db *bun.DB) {
 	})
 }
 
+func testSelectJoinSubquery(t *testing.T, db *bun.DB) {
+	type Author struct {
+		ID   int64 `bun:",pk,autoincrement"`
+		Name string
+	}
+	type Book struct {
+		ID       int64 `bun:",pk,autoincrement"`
+		AuthorID int64
+		Title    string
+	}
+	type BookStats struct {
+		AuthorID int64
+		NumBooks int
+	}
+	type AuthorWithStats struct {
+		bun.BaseModel `bun:"table:authors,alias:author"`
+
+		Author
+		Stats BookStats `bun:"stats,scanonly"`
+	}
+
+	ctx := context.Background()
+	mustResetModel(t, ctx, db, (*Author)(nil), (*Book)(nil))
+
+	authors := []Author{{Name: "a1"}, {Name: "a2"}}
+	_, err := db.NewInsert().Model(&authors).Exec(ctx)
+	require.NoError(t, err)
+
+	books := []Book{
+		{AuthorID: authors[0].ID, Title: "b1"},
+		{AuthorID: authors[0].ID, Title: "b2"},
+		{AuthorID: authors[1].ID, Title: "b3"},
+	}
+	_, err = db.NewInsert().Model(&books).Exec(ctx)
+	require.NoError(t, err)
+
+	var models []AuthorWithStats
+	err = db.NewSelect().
+		Model(&models).
+		JoinSubquery("JOIN", "stats", db.NewSelect().
+			Model((*Book)(nil)).
+			Column("author_id").
+			ColumnExpr("count(*) AS num_books").
+			Group("author_id")).
+		JoinOn("stats.author_id = author.id").
+		OrderExpr("author.id").
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	require.Equal(t, "a1", models[0].Name)
+	require.Equal(t, BookStats{AuthorID: authors[0].ID, NumBooks: 2}, models[0].Stats)
+	require.Equal(t, BookStats{AuthorID: authors[1].ID, NumBooks: 1}, models[1].Stats)
+
+	// The subquery columns are not selected with an explicit column list.
+	var names []string
+	err = db.NewSelect().
+		Model((*AuthorWithStats)(nil)).
+		Column("name").
+		JoinSubquery("JOIN", "stats", db.NewSelect().
+			Model((*Book)(nil)).
+			Column("author_id").
+			ColumnExpr("count(*) AS num_books").
+			Group("author_id")).
+		JoinOn("stats.author_id = author.id").
+		Where("stats.num_books > 1").
+		Scan(ctx, &names)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a1"}, names)
+}
+
 func mustResetModel(tb testing.TB, ctx context.Context, db *bun.DB, models ...interface{}) {
 	err := db.ResetModel(ctx, models...)
 	require.NoError(tb, err, "must reset model")
@@ -1790,3 +1959,513 @@ func mustDropTableOnCleanup(tb testing.TB, ctx context.Context, db *bun.DB, mode
 		}
 	})
 }
+
+func testOptimisticLocking(t *testing.T, db *bun.DB) {
+	type Model struct {
+		ID      int64 `bun:",pk,autoincrement"`
+		Name    string
+		Version int64 `bun:",version"`
+	}
+
+	ctx := context.Background()
+	mustResetModel(t, ctx, db, (*Model)(nil))
+
+	models := []Model{{Name: "a"}, {Name: "b"}}
+	_, err := db.NewInsert().Model(&models).Exec(ctx)
+	require.NoError(t, err)
+
+	model1 := models[0]
+	model2 := models[0]
+
+	model1.Name = "a1"
+	_, err = db.NewUpdate().Model(&model1).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), model1.Version)
+
+	model2.Name = "a2"
+	_, err = db.NewUpdate().Model(&model2).WherePK().Exec(ctx)
+	require.ErrorIs(t, err, bun.ErrStaleObject)
+	require.Equal(t, int64(0), model2.Version)
+
+	_, err = db.NewDelete().Model(&model2).WherePK().Exec(ctx)
+	require.ErrorIs(t, err, bun.ErrStaleObject)
+
+	models[0] = model1
+	models[1].Name = "b1"
+	_, err = db.NewUpdate().Model(&models).Bulk().Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 1}, []int64{models[0].Version, models[1].Version})
+
+	// Rows with a matching version are updated, so roll back the transaction.
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().Model(&[]Model{model1, models[1]}).Bulk().Exec(ctx)
+		return err
+	})
+	require.ErrorIs(t, err, bun.ErrStaleObject)
+
+	var got []Model
+	err = db.NewSelect().Model(&got).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models, got)
+
+	_, err = db.NewDelete().Model(&models[0]).WherePK().Exec(ctx)
+	require.NoError(t, err)
+}
+
+func testTimestamps(t *testing.T, db *bun.DB) {
+	type Model struct {
+		bun.TimestampsModel
+
+		ID              int64 `bun:",pk,autoincrement"`
+		Name            string
+		UpdatedAtBackup time.Time `bun:",nullzero"`
+	}
+
+	ctx := context.Background()
+	mustResetModel(t, ctx, db, (*Model)(nil))
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db = db.WithClock(func() time.Time { return now })
+
+	models := []Model{{Name: "a"}, {Name: "b"}}
+	_, err := db.NewInsert().Model(&models).Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, now, models[0].CreatedAt)
+	require.Equal(t, now, models[0].UpdatedAt)
+
+	created := now
+	now = now.Add(time.Hour)
+
+	models[0].Name = "a1"
+	_, err = db.NewUpdate().Model(&models[0]).OmitZero().WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, now, models[0].UpdatedAt)
+
+	now = now.Add(time.Hour)
+	_, err = db.NewUpdate().Model(&models).Bulk().Exec(ctx)
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = db.NewUpdate().
+		Model((*Model)(nil)).
+		Set("name = ?", "b1").
+		Where("id = ?", models[1].ID).
+		Exec(ctx)
+	require.NoError(t, err)
+
+	var got []Model
+	err = db.NewSelect().Model(&got).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, created, got[0].CreatedAt.UTC())
+	require.Equal(t, now.Add(-time.Hour), got[0].UpdatedAt.UTC())
+	require.Equal(t, created, got[1].CreatedAt.UTC())
+	require.Equal(t, now, got[1].UpdatedAt.UTC())
+
+	// A column that starts with the updated_at name doesn't disable the refresh.
+	now = now.Add(time.Hour)
+	_, err = db.NewUpdate().
+		Model((*Model)(nil)).
+		Set("updated_at_backup = updated_at").
+		Where("id = ?", models[1].ID).
+		Exec(ctx)
+	require.NoError(t, err)
+
+	got = nil
+	err = db.NewSelect().Model(&got).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-time.Hour), got[1].UpdatedAtBackup.UTC())
+	require.Equal(t, now, got[1].UpdatedAt.UTC())
+
+	// updated_at assigned with an identifier placeholder is not refreshed.
+	now = now.Add(time.Hour)
+	_, err = db.NewUpdate().
+		Model((*Model)(nil)).
+		Set("? = ?", bun.Ident("updated_at"), created).
+		Where("id = ?", models[1].ID).
+		Exec(ctx)
+	require.NoError(t, err)
+
+	got = nil
+	err = db.NewSelect().Model(&got).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, created, got[1].UpdatedAt.UTC())
+
+	// The model and the database get the same time even if the clock moves.
+	ticking := db.WithClock(func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	})
+	models[0].Name = "a2"
+	_, err = ticking.NewUpdate().Model(&models[0]).WherePK().Exec(ctx)
+	require.NoError(t, err)
+
+	got = nil
+	err = db.NewSelect().Model(&got).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models[0].UpdatedAt, got[0].UpdatedAt.UTC())
+
+	// Formatting a query doesn't change the model.
+	updatedAt := models[0].UpdatedAt
+	_ = ticking.NewUpdate().Model(&models[0]).WherePK().String()
+	_ = ticking.NewInsert().Model(&models[0]).String()
+	require.Equal(t, updatedAt, models[0].UpdatedAt)
+}
+
+func testSchemaPerTenant(t *testing.T, db *bun.DB) {
+	type User struct {
+		bun.BaseModel `bun:"tenant_users,alias:u"`
+
+		ID   int64 `bun:",pk"`
+		Name string
+	}
+
+	type Tag struct {
+		bun.BaseModel `bun:"tenant_tags,alias:t"`
+
+		ID   int64 `bun:",pk"`
+		Name string
+	}
+
+	type Story struct {
+		bun.BaseModel `bun:"tenant_stories,alias:s"`
+
+		ID     int64 `bun:",pk"`
+		Title  string
+		UserID int64
+		User   *User `bun:"rel:belongs-to,join:user_id=id"`
+		Tags   []Tag `bun:"m2m:tenant_story_tags,join:Story=Tag"`
+	}
+
+	type StoryTag struct {
+		bun.BaseModel `bun:"tenant_story_tags,alias:st"`
+
+		StoryID int64  `bun:",pk"`
+		Story   *Story `bun:"rel:belongs-to,join:story_id=id"`
+		TagID   int64  `bun:",pk"`
+		Tag     *Tag   `bun:"rel:belongs-to,join:tag_id=id"`
+	}
+
+	ctx := context.Background()
+	db.RegisterModel((*StoryTag)(nil))
+	models := []interface{}{(*User)(nil), (*Story)(nil), (*Tag)(nil), (*StoryTag)(nil)}
+	mustResetModel(t, ctx, db, models...)
+
+	var conn bun.IDB = db
+	switch db.Dialect().Name() {
+	case dialect.SQLite:
+		c, err := db.Conn(ctx)
+		require.NoError(t, err)
+		defer c.Close()
+
+		_, err = c.ExecContext(ctx, "ATTACH DATABASE ? AS tenant_1",
+			filepath.Join(t.TempDir(), "tenant_1.db"))
+		require.NoError(t, err)
+		conn = c
+	case dialect.PG:
+		_, err := db.ExecContext(ctx, "DROP SCHEMA IF EXISTS tenant_1 CASCADE")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "CREATE SCHEMA tenant_1")
+		require.NoError(t, err)
+	default:
+		t.Skip()
+	}
+
+	tenantCtx := db.WithSchema(ctx, "tenant_1")
+	for _, model := range models {
+		_, err := conn.NewCreateTable().Model(model).Exec(tenantCtx)
+		require.NoError(t, err)
+	}
+
+	_, err := conn.NewInsert().Model(&User{ID: 1, Name: "tenant"}).Exec(tenantCtx)
+	require.NoError(t, err)
+	_, err = conn.NewInsert().Model(&Story{ID: 1, Title: "story", UserID: 1}).Exec(tenantCtx)
+	require.NoError(t, err)
+	_, err = conn.NewInsert().Model(&Tag{ID: 1, Name: "go"}).Exec(tenantCtx)
+	require.NoError(t, err)
+	_, err = conn.NewInsert().Model(&StoryTag{StoryID: 1, TagID: 1}).Exec(tenantCtx)
+	require.NoError(t, err)
+
+	story := new(Story)
+	err = conn.NewSelect().Model(story).Relation("User").Where("s.id = 1").Scan(tenantCtx)
+	require.NoError(t, err)
+	require.Equal(t, "tenant", story.User.Name)
+
+	count, err := conn.NewSelect().
+		Model((*Story)(nil)).
+		WhereHas("Tags", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("t.name = ?", "go")
+		}).
+		Count(tenantCtx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	count, err = conn.NewSelect().Model((*Story)(nil)).Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	resolved := bun.NewDB(db.DB, db.Dialect(), bun.WithSchemaResolver(func(ctx context.Context) string {
+		return "tenant_1"
+	}))
+	q := resolved.NewSelect().Model((*Story)(nil))
+	if c, ok := conn.(bun.Conn); ok {
+		q = q.Conn(c)
+	}
+	count, err = q.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	for i := len(models) - 1; i >= 0; i-- {
+		_, err := conn.NewDropTable().Model(models[i]).Exec(tenantCtx)
+		require.NoError(t, err)
+	}
+}
+
+func testExplain(t *testing.T, db *bun.DB) {
+	type Model struct {
+		bun.TimestampsModel
+
+		ID        int64 `bun:",pk"`
+		Name      string
+		DeletedAt time.Time `bun:",soft_delete,nullzero"`
+	}
+
+	switch db.Dialect().Name() {
+	case dialect.MSSQL:
+		_, err := db.NewSelect().Model((*Model)(nil)).Explain(ctx, nil)
+		require.Error(t, err)
+		return
+	}
+
+	mustResetModel(t, ctx, db, (*Model)(nil))
+
+	plan, err := db.NewSelect().
+		Model(&Model{Name: "hello"}).
+		Where("?TableAlias.name = ?name").
+		Explain(ctx, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, plan.Columns)
+	require.NotEmpty(t, plan.Rows)
+
+	switch db.Dialect().Name() {
+	case dialect.PG:
+		require.Len(t, plan.Nodes, 1)
+		require.Equal(t, "Seq Scan", plan.Nodes[0].Name)
+		require.Equal(t, "models", plan.Nodes[0].Props["Relation Name"])
+	case dialect.SQLite:
+		require.Len(t, plan.Nodes, 1)
+		require.Equal(t, "SCAN model", plan.Nodes[0].Name)
+
+		_, err := db.NewSelect().Model((*Model)(nil)).Explain(ctx, &bun.ExplainOptions{Analyze: true})
+		require.Error(t, err)
+	default:
+		require.Nil(t, plan.Nodes)
+	}
+
+	// Explain doesn't change the timestamp and soft delete fields.
+	model := &Model{ID: 1, Name: "hello"}
+
+	_, err = db.NewUpdate().
+		Model(model).
+		WherePK().
+		Explain(ctx, nil)
+	require.NoError(t, err)
+	require.True(t, model.UpdatedAt.IsZero())
+
+	_, err = db.NewDelete().
+		Model(model).
+		WherePK().
+		Explain(ctx, nil)
+	require.NoError(t, err)
+	require.True(t, model.DeletedAt.IsZero())
+	require.True(t, model.UpdatedAt.IsZero())
+}
+
+func testSelectCache(t *testing.T, db *bun.DB) {
+	type Country struct {
+		ID   int64 `bun:",pk"`
+		Name string
+	}
+
+	mustResetModel(t, ctx, db, (*Country)(nil))
+
+	cache := bun.NewLRUCache(2)
+	cachedDB := bun.NewDB(db.DB, db.Dialect(), bun.WithCache(cache))
+
+	_, err := db.NewInsert().Model(&Country{ID: 1, Name: "Latvia"}).Exec(ctx)
+	require.NoError(t, err)
+
+	selectCountries := func() []Country {
+		var countries []Country
+		err := cachedDB.NewSelect().
+			Model(&countries).
+			OrderExpr("id").
+			Cache("countries", time.Hour).
+			Scan(ctx)
+		require.NoError(t, err)
+		return countries
+	}
+
+	require.Equal(t, []Country{{ID: 1, Name: "Latvia"}}, selectCountries())
+	require.Equal(t, 1, cache.Len())
+
+	// Changes made without the cache are not visible until the entry is invalidated.
+	_, err = db.NewInsert().Model(&Country{ID: 2, Name: "Estonia"}).Exec(ctx)
+	require.NoError(t, err)
+	require.Len(t, selectCountries(), 1)
+
+	cachedDB.InvalidateCache(ctx, "countries")
+	require.Len(t, selectCountries(), 2)
+
+	// Writes invalidate the entries of their table.
+	_, err = cachedDB.NewDelete().Model((*Country)(nil)).Where("id = 2").Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, cache.Len())
+	require.Len(t, selectCountries(), 1)
+
+	var name string
+	err = cachedDB.NewSelect().
+		Model((*Country)(nil)).
+		Column("name").
+		Where("id = 1").
+		Cache("country", 0).
+		Scan(ctx, &name)
+	require.NoError(t, err)
+	require.Equal(t, "Latvia", name)
+	require.Equal(t, 2, cache.Len())
+
+	name = ""
+	err = cachedDB.NewSelect().
+		Model((*Country)(nil)).
+		Column("name").
+		Where("id = 1").
+		Cache("country", 0).
+		Scan(ctx, &name)
+	require.NoError(t, err)
+	require.Equal(t, "Latvia", name)
+
+	// The least recently used entry is evicted.
+	var count int
+	err = cachedDB.NewSelect().
+		Model((*Country)(nil)).
+		ColumnExpr("count(*)").
+		Cache("count", 0).
+		Scan(ctx, &count)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, 2, cache.Len())
+
+	_, err = db.NewInsert().Model(&Country{ID: 3, Name: "Lithuania"}).Exec(ctx)
+	require.NoError(t, err)
+	require.Len(t, selectCountries(), 2)
+
+	// Writes in a transaction invalidate the entries again after the commit,
+	// because the old rows can be cached before the changes are visible.
+	tx, err := cachedDB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	_, err = tx.NewInsert().Model(&Country{ID: 4, Name: "Finland"}).Exec(ctx)
+	require.NoError(t, err)
+	require.Len(t, selectCountries(), 2)
+
+	require.NoError(t, tx.Commit())
+	require.Len(t, selectCountries(), 3)
+
+	// Model-less queries invalidate the entries of the model table.
+	_, err = cachedDB.NewUpdate().
+		Table("countries").
+		Set("name = ?", "Suomi").
+		Where("id = 4").
+		Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Suomi", selectCountries()[2].Name)
+
+	// Entries are tagged with the tables of the CTEs.
+	countCountries := func() int {
+		var count int
+		err := cachedDB.NewSelect().
+			With("c", cachedDB.NewSelect().Model((*Country)(nil))).
+			Table("c").
+			ColumnExpr("count(*)").
+			Cache("cte", 0).
+			Scan(ctx, &count)
+		require.NoError(t, err)
+		return count
+	}
+	require.Equal(t, 3, countCountries())
+
+	_, err = cachedDB.NewDelete().Model((*Country)(nil)).Where("id = 4").Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, countCountries())
+
+	// Queries with raw joins are not cached.
+	cache.Invalidate(ctx, "countries")
+	err = cachedDB.NewSelect().
+		Model((*Country)(nil)).
+		ColumnExpr("count(*)").
+		Join("JOIN countries AS c2 ON c2.id = country.id").
+		Cache("join", 0).
+		Scan(ctx, &count)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, 0, cache.Len())
+}
+
+func testSaveRelationsInTx(t *testing.T, db *bun.DB) {
+	type Child struct {
+		bun.BaseModel `bun:"rel_children"`
+
+		ID       int64 `bun:",pk,autoincrement"`
+		ParentID int64
+		Name     string `bun:",nullzero,notnull"`
+	}
+
+	type Tag struct {
+		bun.BaseModel `bun:"rel_tags"`
+
+		ID int64 `bun:",pk"`
+	}
+
+	type Parent struct {
+		bun.BaseModel `bun:"rel_parents"`
+
+		ID       int64 `bun:",pk,autoincrement"`
+		Name     string
+		Children []Child `bun:"rel:has-many,join:id=parent_id"`
+		Tags     []Tag   `bun:"m2m:rel_parent_tags,join:Parent=Tag"`
+	}
+
+	// ParentTag has no primary key or unique constraint.
+	type ParentTag struct {
+		bun.BaseModel `bun:"rel_parent_tags"`
+
+		ParentID int64
+		Parent   *Parent `bun:"rel:belongs-to,join:parent_id=id"`
+		TagID    int64
+		Tag      *Tag `bun:"rel:belongs-to,join:tag_id=id"`
+	}
+
+	db.RegisterModel((*ParentTag)(nil))
+	mustResetModel(t, ctx, db, (*Parent)(nil), (*Child)(nil), (*Tag)(nil), (*ParentTag)(nil))
+
+	// The parent is rolled back when a child can't be saved.
+	parent := &Parent{Name: "parent", Children: []Child{{Name: ""}}}
+	_, err := db.NewInsert().Model(parent).WithRelations("Children").Exec(ctx)
+	require.Error(t, err)
+
+	count, err := db.NewSelect().Model((*Parent)(nil)).Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	parent = &Parent{Name: "parent", Tags: []Tag{{ID: 1}}}
+	_, err = db.NewInsert().Model(parent).WithRelations("Tags").Exec(ctx)
+	require.NoError(t, err)
+
+	// The existing m2m rows are not inserted again without a unique constraint.
+	_, err = db.NewUpdate().Model(parent).WherePK().WithRelations("Tags").Exec(ctx)
+	require.NoError(t, err)
+
+	count, err = db.NewSelect().Model((*ParentTag)(nil)).Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
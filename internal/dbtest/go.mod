@@ -20,8 +20,14 @@ replace github.com/uptrace/bun/dialect/sqlitedialect => ../../dialect/sqlitedial
 
 replace github.com/uptrace/bun/dialect/mssqldialect => ../../dialect/mssqldialect
 
+replace github.com/uptrace/bun/extra/bunaudit => ../../extra/bunaudit
+
 replace github.com/uptrace/bun/extra/bundebug => ../../extra/bundebug
 
+replace github.com/uptrace/bun/extra/bunexplain => ../../extra/bunexplain
+
+replace github.com/uptrace/bun/extra/bunnplusone => ../../extra/bunnplusone
+
 require (
 	github.com/bradleyjkemp/cupaloy v2.3.0+incompatible
 	github.com/brianvoe/gofakeit/v6 v6.4.1
@@ -38,7 +44,10 @@ require (
 	github.com/uptrace/bun/dialect/sqlitedialect v1.2.6
 	github.com/uptrace/bun/driver/pgdriver v1.2.6
 	github.com/uptrace/bun/driver/sqliteshim v1.2.6
+	github.com/uptrace/bun/extra/bunaudit v1.2.6
 	github.com/uptrace/bun/extra/bundebug v1.2.6
+	github.com/uptrace/bun/extra/bunexplain v1.2.6
+	github.com/uptrace/bun/extra/bunnplusone v1.2.6
 	github.com/wk8/go-ordered-map/v2 v2.1.9-0.20240816141633-0a40785b4f41
 )
 
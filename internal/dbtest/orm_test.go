@@ -2,6 +2,7 @@ package dbtest_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
@@ -34,6 +35,11 @@ func TestORM(t *testing.T) {
 		{testRelationBelongsToSelf},
 		{testCompositeHasMany},
 		{testCompositeM2M},
+		{testRelationLimitPerParent},
+		{testRelationCount},
+		{testWhereHas},
+		{testSaveRelations},
+		{testM2MSync},
 	}
 
 	testEachDB(t, func(t *testing.T, dbName string, db *bun.DB) {
@@ -519,6 +525,301 @@ func testCompositeM2M(t *testing.T, db *bun.DB) {
 	require.Equal(t, 1, len(ordersOut2[0].Items))
 }
 
+func testRelationLimitPerParent(t *testing.T, db *bun.DB) {
+	var authors []Author
+	err := db.NewSelect().
+		Model(&authors).
+		Column("author.id").
+		Relation("Books", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("book.id", "book.author_id").OrderExpr("book.id DESC").Limit(1)
+		}).
+		OrderExpr("author.id ASC").
+		Scan(ctx)
+	if !db.HasFeature(feature.WindowFunctions) {
+		require.Error(t, err)
+		return
+	}
+	require.NoError(t, err)
+	require.Len(t, authors, 3)
+	require.Equal(t, []*Book{{ID: 101, AuthorID: 10}}, authors[0].Books)
+	require.Equal(t, []*Book{{ID: 102, AuthorID: 11}}, authors[1].Books)
+	require.Nil(t, authors[2].Books)
+
+	var genres []Genre
+	err = db.NewSelect().
+		Model(&genres).
+		Column("genre.id").
+		Where("genre.id IN (?)", bun.In([]int{1, 2})).
+		Relation("Books", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("book.id").OrderExpr("book.id DESC").Limit(1)
+		}).
+		OrderExpr("genre.id ASC").
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, genres, 2)
+	require.Equal(t, []Book{{ID: 101}}, genres[0].Books)
+	require.Equal(t, []Book{{ID: 100}}, genres[1].Books)
+
+	// The row number can't be locked, so FOR is rejected instead of being dropped.
+	err = db.NewSelect().
+		Model(&authors).
+		Column("author.id").
+		Relation("Books", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("book.id", "book.author_id").Limit(1).For("UPDATE")
+		}).
+		Scan(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can't use FOR")
+
+	// Only the row number column is ignored when scanning the relation.
+	err = db.NewSelect().
+		Model(&authors).
+		Column("author.id").
+		Relation("Books", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("book.id", "book.author_id").ColumnExpr("1 AS _unknown").Limit(1)
+		}).
+		Scan(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `does not have column "_unknown"`)
+}
+
+func testRelationCount(t *testing.T, db *bun.DB) {
+	type AuthorWithStats struct {
+		Author `bun:",extend"`
+
+		NumBooks  int `bun:",scanonly"`
+		BookIDSum int `bun:",scanonly"`
+	}
+
+	var authors []AuthorWithStats
+	err := db.NewSelect().
+		Model(&authors).
+		RelationCount("Books", "num_books").
+		RelationAggregate("Books", "coalesce(sum(book.id), 0)", "book_id_sum").
+		OrderExpr("author.id ASC").
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, authors, 3)
+	require.Equal(t, []int{2, 1, 0}, []int{authors[0].NumBooks, authors[1].NumBooks, authors[2].NumBooks})
+	require.Equal(t, 201, authors[0].BookIDSum)
+	require.Equal(t, "author 1", authors[0].Name)
+
+	type BookWithStats struct {
+		Book `bun:",extend"`
+
+		NumGenres   int `bun:",scanonly"`
+		NumComments int `bun:",scanonly"`
+	}
+
+	var books []BookWithStats
+	err = db.NewSelect().
+		Model(&books).
+		Column("book.id").
+		RelationCount("Genres", "num_genres").
+		RelationCount("Comments", "num_comments", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("comment.text != ?", "comment2")
+		}).
+		OrderExpr("book.id ASC").
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, books, 3)
+	require.Equal(t, []int{2, 1, 0}, []int{books[0].NumGenres, books[1].NumGenres, books[2].NumGenres})
+	require.Equal(t, []int{1, 0, 0}, []int{books[0].NumComments, books[1].NumComments, books[2].NumComments})
+}
+
+func testWhereHas(t *testing.T, db *bun.DB) {
+	selectIDs := func(t *testing.T, q *bun.SelectQuery) []int {
+		var ids []int
+		err := q.OrderExpr("1").Scan(ctx, &ids)
+		require.NoError(t, err)
+		return ids
+	}
+
+	ids := selectIDs(t, db.NewSelect().
+		Model((*Author)(nil)).
+		Column("author.id").
+		WhereHas("Books.Genres", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("genre.id = ?", 2)
+		}))
+	require.Equal(t, []int{10}, ids)
+
+	ids = selectIDs(t, db.NewSelect().
+		Model((*Author)(nil)).
+		Column("author.id").
+		WhereDoesntHave("Books"))
+	require.Equal(t, []int{12}, ids)
+
+	ids = selectIDs(t, db.NewSelect().
+		Model((*Book)(nil)).
+		Column("book.id").
+		WhereHas("Editor", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("author.name = ?", "author 3")
+		}))
+	require.Equal(t, []int{101}, ids)
+
+	ids = selectIDs(t, db.NewSelect().
+		Model((*Book)(nil)).
+		Column("book.id").
+		WhereHas("Comments"))
+	require.Equal(t, []int{100}, ids)
+
+	err := db.NewSelect().
+		Model((*Book)(nil)).
+		WhereHas("Comments.Unknown").
+		Scan(ctx)
+	require.Error(t, err)
+}
+
+func testSaveRelations(t *testing.T, db *bun.DB) {
+	book := &Book{
+		ID:     103,
+		Title:  "book 4",
+		Author: Author{ID: 13, Name: "author 4", AvatarID: 1},
+		Editor: &Author{ID: 11, Name: "author 2 edited", AvatarID: 2},
+		Genres: []Genre{
+			{ID: 1, Name: "genre 1"},
+			{ID: 5, Name: "genre 5"},
+		},
+		Translations: []Translation{{ID: 1003, Lang: "en"}},
+		Comments:     []Comment{{Text: "comment4"}},
+	}
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().
+			Model(book).
+			WithRelations("Author", "Editor", "Genres", "Translations", "Comments").
+			Exec(ctx)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, 13, book.AuthorID)
+	require.Equal(t, 11, book.EditorID)
+	require.Equal(t, 103, book.Translations[0].BookID)
+	require.Equal(t, 103, book.Comments[0].TrackableID)
+
+	got := new(Book)
+	err = db.NewSelect().
+		Model(got).
+		Relation("Author").
+		Relation("Editor").
+		Relation("Genres", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("genre.id")
+		}).
+		Relation("Translations").
+		Relation("Comments").
+		Where("book.id = ?", 103).
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "author 4", got.Author.Name)
+	require.Equal(t, "author 2 edited", got.Editor.Name)
+	require.Len(t, got.Genres, 2)
+	require.Equal(t, []string{"genre 1", "genre 5"}, []string{got.Genres[0].Name, got.Genres[1].Name})
+	require.Len(t, got.Translations, 1)
+	require.Equal(t, "en", got.Translations[0].Lang)
+	require.Len(t, got.Comments, 1)
+	require.Equal(t, "book", got.Comments[0].TrackableType)
+
+	book.Title = "book 4 edited"
+	book.Genres = append(book.Genres, Genre{ID: 2, Name: "genre 2"})
+	book.Translations = append(book.Translations, Translation{ID: 1004, Lang: "fr"})
+	book.Comments = nil
+
+	_, err = db.NewUpdate().
+		Model(book).
+		WherePK().
+		WithRelations("Genres", "Translations").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	got = new(Book)
+	err = db.NewSelect().
+		Model(got).
+		Relation("Genres").
+		Relation("Translations").
+		Where("book.id = ?", 103).
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "book 4 edited", got.Title)
+	require.Len(t, got.Genres, 3)
+	require.Len(t, got.Translations, 2)
+
+	_, err = db.NewInsert().
+		Model(&Book{Title: "book 5"}).
+		WithRelations("Unknown").
+		Exec(ctx)
+	require.Error(t, err)
+}
+
+func testM2MSync(t *testing.T, db *bun.DB) {
+	selectBookGenres := func(t *testing.T, bookID int) []BookGenre {
+		var rows []BookGenre
+		err := db.NewSelect().
+			Model(&rows).
+			Where("book_id = ?", bookID).
+			Order("genre_id").
+			Scan(ctx)
+		require.NoError(t, err)
+		return rows
+	}
+	genreIDs := func(rows []BookGenre) []int {
+		ids := make([]int, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.GenreID)
+		}
+		return ids
+	}
+
+	book := &Book{ID: 102}
+
+	err := db.M2M(book, "Genres").Attach(ctx, &Genre{ID: 1}, []Genre{{ID: 2}})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, genreIDs(selectBookGenres(t, 102)))
+
+	err = db.M2M(book, "Genres").Attach(ctx, &Genre{ID: 2})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, genreIDs(selectBookGenres(t, 102)))
+
+	err = db.M2M(book, "Genres").Detach(ctx, Genre{ID: 1})
+	require.NoError(t, err)
+	require.Equal(t, []int{2}, genreIDs(selectBookGenres(t, 102)))
+
+	err = db.M2M(book, "Genres").Sync(ctx, &BookGenre{GenreID: 1, Genre_Rating: 5}, &Genre{ID: 3})
+	require.NoError(t, err)
+	rows := selectBookGenres(t, 102)
+	require.Equal(t, []int{1, 3}, genreIDs(rows))
+	require.Equal(t, 5, rows[0].Genre_Rating)
+
+	err = db.M2M(&Book{ID: 100}, "Genres").Sync(ctx, &BookGenre{GenreID: 2, Genre_Rating: 1})
+	require.NoError(t, err)
+	rows = selectBookGenres(t, 100)
+	require.Equal(t, []int{2}, genreIDs(rows))
+	require.Equal(t, 1, rows[0].Genre_Rating)
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := db.M2M(book, "Genres").Conn(tx).Sync(ctx, &Genre{ID: 2})
+		require.NoError(t, err)
+
+		var rows []BookGenre
+		err = tx.NewSelect().Model(&rows).Where("book_id = ?", 102).Scan(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, genreIDs(rows))
+
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	require.Equal(t, []int{1, 3}, genreIDs(selectBookGenres(t, 102)))
+
+	err = db.M2M(book, "Genres").Sync(ctx)
+	require.NoError(t, err)
+	require.Empty(t, selectBookGenres(t, 102))
+
+	err = db.M2M(book, "Author").Attach(ctx, &Author{ID: 10})
+	require.Error(t, err)
+
+	err = db.M2M(book, "Genres").Attach(ctx, &Author{ID: 10})
+	require.Error(t, err)
+}
+
 type Genre struct {
 	ID     int `bun:",pk"`
 	Name   string
@@ -2,12 +2,21 @@ package dbtest_test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/extra/bunaudit"
+	"github.com/uptrace/bun/extra/bunexplain"
+	"github.com/uptrace/bun/extra/bunnplusone"
 	"github.com/uptrace/bun/schema"
 )
 
@@ -78,6 +87,475 @@ func testQueryHook(t *testing.T, dbName string, db *bun.DB) {
 	}
 }
 
+func TestQueryRedaction(t *testing.T) {
+	testEachDB(t, testQueryRedaction)
+}
+
+type SensitiveUser struct {
+	ID       int64 `bun:",pk"`
+	Name     string
+	Password string `bun:",sensitive"`
+}
+
+func testQueryRedaction(t *testing.T, dbName string, db *bun.DB) {
+	mustResetModel(t, ctx, db, (*SensitiveUser)(nil))
+
+	hook := &queryHook{}
+	db.AddQueryHook(hook)
+
+	{
+		hook.reset()
+		hook.beforeQuery = func(
+			ctx context.Context, event *bun.QueryEvent,
+		) context.Context {
+			// The redacted queries are not formatted unless a hook requests them.
+			require.Equal(t, event.Operation(), event.RedactedQuery(bun.RedactSensitive))
+			require.Equal(t, event.Operation(), event.RedactedQuery(bun.RedactValues))
+			return ctx
+		}
+
+		user := &SensitiveUser{ID: 1, Name: "alice", Password: "secret"}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+		hook.require(t)
+
+		_, err = db.NewDelete().Model(user).WherePK().Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	db.AddQueryHook(redactionHook(bun.RedactSensitive))
+	db.AddQueryHook(redactionHook(bun.RedactValues))
+
+	{
+		hook.reset()
+		hook.beforeQuery = func(
+			ctx context.Context, event *bun.QueryEvent,
+		) context.Context {
+			require.Equal(t, event.Query, event.RedactedQuery(bun.RedactNone))
+			require.Contains(t, event.Query, "'secret'")
+
+			query := event.RedactedQuery(bun.RedactSensitive)
+			require.Contains(t, query, "'alice'")
+			require.Contains(t, query, "'***'")
+			require.NotContains(t, query, "secret")
+
+			query = event.RedactedQuery(bun.RedactValues)
+			require.NotContains(t, query, "alice")
+			require.NotContains(t, query, "secret")
+			return ctx
+		}
+
+		user := &SensitiveUser{ID: 1, Name: "alice", Password: "secret"}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+		hook.require(t)
+	}
+
+	{
+		hook.reset()
+		hook.beforeQuery = func(
+			ctx context.Context, event *bun.QueryEvent,
+		) context.Context {
+			require.Equal(t, "SELECT ?", event.RedactedQuery(bun.RedactValues))
+			require.Equal(t, "SELECT 'secret'", event.RedactedQuery(bun.RedactSensitive))
+			return ctx
+		}
+
+		_, err := db.Exec("SELECT ?", "secret")
+		require.NoError(t, err)
+		hook.require(t)
+	}
+
+	{
+		hook.reset()
+		hook.beforeQuery = func(ctx context.Context, event *bun.QueryEvent) context.Context {
+			return ctx
+		}
+		mustResetModel(t, ctx, db, (*RedactedScopedUser)(nil))
+		redactedScopeCalls = 0
+
+		var redacted []string
+		hook.afterQuery = func(ctx context.Context, event *bun.QueryEvent) {
+			for i := 0; i < 3; i++ {
+				redacted = append(redacted, event.RedactedQuery(bun.RedactValues))
+			}
+		}
+
+		var users []RedactedScopedUser
+		err := db.NewSelect().Model(&users).Where("id = ?", 1).Scan(ctx)
+		require.NoError(t, err)
+
+		// The redacted query is formatted once before the query is executed.
+		require.Equal(t, 1, redactedScopeCalls)
+		require.Len(t, redacted, 3)
+		require.Contains(t, redacted[0], "id = ?")
+		require.Contains(t, redacted[0], "name IS NOT NULL")
+	}
+
+	hook.beforeQuery = func(ctx context.Context, event *bun.QueryEvent) context.Context {
+		return ctx
+	}
+	hook.afterQuery = nil
+}
+
+type RedactedScopedUser struct {
+	ID   int64 `bun:",pk"`
+	Name string
+}
+
+var redactedScopeCalls int
+
+func (*RedactedScopedUser) DefaultScopes() map[string]func(bun.QueryBuilder) bun.QueryBuilder {
+	return map[string]func(bun.QueryBuilder) bun.QueryBuilder{
+		"named": func(q bun.QueryBuilder) bun.QueryBuilder {
+			redactedScopeCalls++
+			return q.Where("name IS NOT NULL")
+		},
+	}
+}
+
+func TestExplainHook(t *testing.T) {
+	testEachDB(t, testExplainHook)
+}
+
+func testExplainHook(t *testing.T, dbName string, db *bun.DB) {
+	if db.Dialect().Name() == dialect.MSSQL {
+		t.Skip("EXPLAIN is not supported")
+	}
+
+	mustResetModel(t, ctx, db, (*SensitiveUser)(nil))
+
+	type explained struct {
+		event *bun.QueryEvent
+		plan  string
+		err   error
+	}
+
+	plans := make(chan explained, 1)
+	db.AddQueryHook(bunexplain.NewQueryHook(
+		bunexplain.WithSlowQueryThreshold(0),
+		bunexplain.WithHandler(func(
+			ctx context.Context, event *bun.QueryEvent, plan string, err error,
+		) {
+			plans <- explained{event: event, plan: plan, err: err}
+		}),
+	))
+
+	_, err := db.NewInsert().Model(&SensitiveUser{ID: 1, Name: "alice"}).Exec(ctx)
+	require.NoError(t, err)
+
+	var users []SensitiveUser
+	err = db.NewSelect().Model(&users).Where("name = ?", "alice").Scan(ctx)
+	require.NoError(t, err)
+
+	// EXPLAIN runs in the background after the query returns.
+	select {
+	case res := <-plans:
+		require.NoError(t, res.err)
+		require.Equal(t, "SELECT", res.event.Operation())
+		require.NotEmpty(t, res.plan)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the query is not explained")
+	}
+
+	// The next slow query is not explained until the interval passes.
+	err = db.NewSelect().Model(&users).Scan(ctx)
+	require.NoError(t, err)
+	select {
+	case <-plans:
+		t.Fatal("the query is explained within the interval")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestQueryEvent(t *testing.T) {
+	testEachDB(t, testQueryEvent)
+}
+
+func testQueryEvent(t *testing.T, dbName string, db *bun.DB) {
+	var events []*bun.QueryEvent
+	var callers []runtime.Frame
+	db.AddQueryHook(&queryHook{
+		beforeQuery: func(ctx context.Context, event *bun.QueryEvent) context.Context {
+			return ctx
+		},
+		afterQuery: func(ctx context.Context, event *bun.QueryEvent) {
+			events = append(events, event)
+			callers = append(callers, event.Caller())
+		},
+	})
+
+	_, err := db.NewSelect().ColumnExpr("1").Exec(ctx)
+	require.NoError(t, err)
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewSelect().ColumnExpr("1").Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "SELECT 1")
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 5)
+
+	require.Equal(t, db.DB, events[0].Conn)
+	require.False(t, events[0].InTx)
+
+	require.Equal(t, "BEGIN", events[1].Operation())
+	require.False(t, events[1].InTx)
+
+	require.True(t, events[2].InTx)
+	require.True(t, events[3].InTx)
+	require.True(t, events[4].InTx)
+	require.Equal(t, "COMMIT", events[4].Operation())
+	require.Equal(t, events[2].Conn, events[3].Conn)
+	require.Equal(t, events[2].Conn, events[4].Conn)
+	require.NotEqual(t, events[0].Conn, events[2].Conn)
+
+	for _, caller := range callers[:4] {
+		require.Contains(t, caller.Function, "testQueryEvent")
+		require.Contains(t, caller.File, "query_hook_test.go")
+	}
+}
+
+func TestQueryComment(t *testing.T) {
+	testEachDB(t, testQueryComment)
+}
+
+func testQueryComment(t *testing.T, dbName string, db *bun.DB) {
+	db = bun.NewDB(db.DB, db.Dialect(),
+		bun.WithCommenter(func(ctx context.Context) map[string]string {
+			return map[string]string{"framework": "bun", "route": "/default"}
+		}),
+		bun.WithCallerComment(),
+	)
+
+	var queries []string
+	db.AddQueryHook(&queryHook{
+		beforeQuery: func(ctx context.Context, event *bun.QueryEvent) context.Context {
+			queries = append(queries, event.Query)
+			return ctx
+		},
+	})
+
+	ctx := bun.WithCommentTags(ctx, map[string]string{"route": "/users/:id", "action": "it's"})
+
+	var num int
+	err := db.NewSelect().ColumnExpr("1").Comment("select */ one").Scan(ctx, &num)
+	require.NoError(t, err)
+	require.Equal(t, 1, num)
+
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+
+	require.Len(t, queries, 2)
+
+	require.True(t, strings.HasPrefix(queries[0], `/* select *\/ one */ SELECT 1`), queries[0])
+	for _, query := range queries {
+		idx := strings.Index(query, " /*action=")
+		require.NotEqual(t, -1, idx, query)
+		require.True(t, strings.HasPrefix(query[idx:],
+			" /*action='it%27s',caller='dbtest_test.testQueryComment%20query_hook_test.go%3A"), query)
+		require.True(t, strings.HasSuffix(query,
+			"',framework='bun',route='%2Fusers%2F%3Aid'*/"), query)
+	}
+}
+
+func TestNPlusOneHook(t *testing.T) {
+	testEachDB(t, testNPlusOneHook)
+}
+
+type NPlusOneAuthor struct {
+	ID   int64 `bun:",pk"`
+	Name string
+}
+
+type NPlusOneBook struct {
+	ID       int64 `bun:",pk"`
+	Title    string
+	AuthorID int64
+	Author   *NPlusOneAuthor `bun:"rel:belongs-to,join:author_id=id"`
+}
+
+func testNPlusOneHook(t *testing.T, dbName string, db *bun.DB) {
+	mustResetModel(t, ctx, db, (*NPlusOneAuthor)(nil), (*NPlusOneBook)(nil))
+
+	var reports []*bunnplusone.Report
+	db.AddQueryHook(bunnplusone.NewQueryHook(
+		bunnplusone.WithMaxQueries(2),
+		bunnplusone.WithHandler(func(ctx context.Context, report *bunnplusone.Report) {
+			reports = append(reports, report)
+		}),
+	))
+
+	books := make([]NPlusOneBook, 4)
+	authors := make([]NPlusOneAuthor, 4)
+	for i := range books {
+		authors[i] = NPlusOneAuthor{ID: int64(i + 1), Name: fmt.Sprint("author", i)}
+		books[i] = NPlusOneBook{ID: int64(i + 1), Title: fmt.Sprint("book", i), AuthorID: int64(i + 1)}
+	}
+	_, err := db.NewInsert().Model(&authors).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewInsert().Model(&books).Exec(ctx)
+	require.NoError(t, err)
+
+	ctx := bunnplusone.WithScope(ctx)
+
+	books = nil
+	err = db.NewSelect().Model(&books).Scan(ctx)
+	require.NoError(t, err)
+
+	for i := range books {
+		books[i].Author = new(NPlusOneAuthor)
+		err := db.NewSelect().Model(books[i].Author).Where("id = ?", books[i].AuthorID).Scan(ctx)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, reports, 1)
+	report := reports[0]
+	require.Equal(t, 3, report.Count)
+	require.Equal(t, "NPlusOneBook", report.Model)
+	require.Equal(t, "Author", report.Relation)
+	require.Contains(t, report.File, "query_hook_test.go")
+	require.Contains(t, report.Query, "id = ?")
+
+	// Queries executed without a scope are not counted.
+	for i := 0; i < 3; i++ {
+		err := db.NewSelect().Model(&books).Scan(context.Background())
+		require.NoError(t, err)
+	}
+	require.Len(t, reports, 1)
+}
+
+func TestAuditHook(t *testing.T) {
+	testEachDB(t, testAuditHook)
+}
+
+type AuditUser struct {
+	bunaudit.Audited
+
+	ID       int64 `bun:",pk"`
+	Name     string
+	Password string `bun:",sensitive"`
+}
+
+func testAuditHook(t *testing.T, dbName string, db *bun.DB) {
+	mustResetModel(t, ctx, db, (*AuditUser)(nil), (*bunaudit.Entry)(nil))
+
+	db.AddQueryHook(bunaudit.NewQueryHook())
+	ctx := bunaudit.WithActor(ctx, "alice")
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		users := []AuditUser{
+			{ID: 1, Name: "user1", Password: "secret1"},
+			{ID: 2, Name: "user2", Password: "secret2"},
+		}
+		if _, err := tx.NewInsert().Model(&users).Exec(ctx); err != nil {
+			return err
+		}
+
+		user := &AuditUser{ID: 1, Name: "renamed", Password: "secret1"}
+		if _, err := tx.NewUpdate().Model(user).WherePK().Exec(ctx); err != nil {
+			return err
+		}
+
+		_, err := tx.NewDelete().Model((*AuditUser)(nil)).Where("id = ?", 2).Exec(ctx)
+		return err
+	})
+	require.NoError(t, err)
+
+	var entries []bunaudit.Entry
+	err = db.NewSelect().Model(&entries).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	for _, entry := range entries {
+		require.Equal(t, "audit_users", entry.TableName)
+		require.Equal(t, "alice", entry.Actor)
+	}
+
+	require.Equal(t, "INSERT", entries[0].Operation)
+	require.Equal(t, "1", entries[0].PK)
+	require.Nil(t, entries[0].Before)
+	require.Equal(t, "user1", entries[0].After["name"])
+	require.Equal(t, "***", entries[0].After["password"])
+
+	require.Equal(t, "UPDATE", entries[2].Operation)
+	require.Equal(t, "1", entries[2].PK)
+	require.Equal(t, map[string]interface{}{"name": "user1"}, entries[2].Before)
+	require.Equal(t, map[string]interface{}{"name": "renamed"}, entries[2].After)
+
+	require.Equal(t, "DELETE", entries[3].Operation)
+	require.Equal(t, "2", entries[3].PK)
+	require.Equal(t, "user2", entries[3].Before["name"])
+	require.Nil(t, entries[3].After)
+
+	// The audit entries are rolled back with the transaction.
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*AuditUser)(nil)).Where("id = ?", 1).Exec(ctx)
+		require.NoError(t, err)
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	count, err := db.NewSelect().Model((*bunaudit.Entry)(nil)).Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+
+	// The rows are selected using the CTEs and tables of the query.
+	users := []AuditUser{{ID: 1, Name: "bulk", Password: "secret1"}}
+	_, err = db.NewUpdate().Model(&users).Column("name").Bulk().Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewDelete().
+		With("ids", db.NewSelect().ColumnExpr("1 AS id")).
+		Model((*AuditUser)(nil)).
+		Where("id IN (SELECT id FROM ids)").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	lastID := entries[3].ID
+	entries = nil
+	err = db.NewSelect().Model(&entries).Where("id > ?", lastID).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, "UPDATE", entries[0].Operation)
+	require.Equal(t, "1", entries[0].PK)
+	require.Equal(t, map[string]interface{}{"name": "renamed"}, entries[0].Before)
+	require.Equal(t, map[string]interface{}{"name": "bulk"}, entries[0].After)
+
+	require.Equal(t, "DELETE", entries[1].Operation)
+	require.Equal(t, "1", entries[1].PK)
+	require.Equal(t, "bulk", entries[1].Before["name"])
+
+	// The changes made with a DB that shares the connection pool,
+	// but doesn't have the hook, are not recorded.
+	other := bun.NewDB(db.DB, db.Dialect())
+	_, err = other.NewInsert().Model(&AuditUser{ID: 3, Name: "user3"}).Exec(ctx)
+	require.NoError(t, err)
+
+	count, err = db.NewSelect().Model((*bunaudit.Entry)(nil)).Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 6, count)
+}
+
+// redactionHook requests the redaction of the queries.
+type redactionHook bun.Redaction
+
+var _ bun.RedactionHook = redactionHook(0)
+
+func (h redactionHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h redactionHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {}
+
+func (h redactionHook) Redaction() bun.Redaction {
+	return bun.Redaction(h)
+}
+
 type queryHook struct {
 	startTime time.Time
 	endTime   time.Time
@@ -108,3 +586,82 @@ func (h *queryHook) require(t *testing.T) {
 	require.WithinDuration(t, h.startTime, time.Now(), time.Second)
 	require.WithinDuration(t, h.endTime, time.Now(), time.Second)
 }
+
+func TestTxHook(t *testing.T) {
+	testEachDB(t, testTxHook)
+}
+
+type txHook struct {
+	events []string
+	ctxKey struct{}
+}
+
+var _ bun.TxHook = (*txHook)(nil)
+
+func (h *txHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if ctx.Value(h.ctxKey) != nil {
+		h.events = append(h.events, "query in tx")
+	}
+	return ctx
+}
+
+func (h *txHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.RowsScanned > 0 {
+		h.events = append(h.events, fmt.Sprintf("scanned %d", event.RowsScanned))
+	}
+}
+
+func (h *txHook) BeforeTx(ctx context.Context, event *bun.TxEvent) context.Context {
+	if event.Savepoint != "" {
+		h.events = append(h.events, "savepoint")
+	} else {
+		h.events = append(h.events, "begin")
+	}
+	return context.WithValue(ctx, h.ctxKey, true)
+}
+
+func (h *txHook) AfterTx(ctx context.Context, event *bun.TxEvent) {
+	h.events = append(h.events, strings.ToLower(event.Operation))
+}
+
+func testTxHook(t *testing.T, dbName string, db *bun.DB) {
+	hook := &txHook{}
+	db = bun.NewDB(db.DB, db.Dialect())
+	db.AddQueryHook(hook)
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var nums []int
+		if err := tx.NewSelect().ColumnExpr("1").Scan(ctx, &nums); err != nil {
+			return err
+		}
+		return tx.RunInTx(ctx, nil, func(ctx context.Context, sp bun.Tx) error {
+			return errors.New("rollback savepoint")
+		})
+	})
+	require.Error(t, err)
+	require.Equal(t, []string{
+		"begin",
+		"query in tx", // BEGIN
+		"query in tx", // SELECT
+		"scanned 1",
+		"savepoint",
+		"query in tx", // SAVEPOINT
+		"query in tx", // ROLLBACK TO SAVEPOINT
+		"rollback",
+		"query in tx", // ROLLBACK
+		"rollback",
+	}, hook.events)
+
+	hook.events = nil
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	require.ErrorIs(t, tx.Rollback(), sql.ErrTxDone)
+	require.Equal(t, []string{
+		"begin",
+		"query in tx", // BEGIN
+		"query in tx", // COMMIT
+		"commit",
+		"query in tx", // ROLLBACK
+	}, hook.events)
+}
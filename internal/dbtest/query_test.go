@@ -56,6 +56,29 @@ func TestQuery(t *testing.T) {
 		DeletedAt time.Time `bun:",soft_delete"`
 	}
 
+	type Versioned struct {
+		ID      int64 `bun:",pk,autoincrement"`
+		Name    string
+		Version int64 `bun:",version"`
+	}
+
+	type SoftDeleteBool struct {
+		ID        int64 `bun:",pk,autoincrement"`
+		IsDeleted bool  `bun:",soft_delete"`
+		DeletedBy string
+	}
+
+	type Timestamped struct {
+		bun.TimestampsModel
+
+		ID   int64 `bun:",pk,autoincrement"`
+		Name string
+	}
+
+	clock := func() time.Time {
+		return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+
 	type test struct {
 		id    int
 		query func(db *bun.DB) schema.QueryAppender
@@ -1596,6 +1619,177 @@ func TestQuery(t *testing.T) {
 				return db.NewDelete().Model(&Model{}).WherePK().Returning("*")
 			},
 		},
+		{
+			id: 173,
+			query: func(db *bun.DB) schema.QueryAppender {
+				return db.NewSelect().
+					Model((*Story)(nil)).
+					ColumnExpr("row_number() ? AS rank", bun.Over("w")).
+					Window("w", func(w *bun.WindowDef) {
+						w.Partition("user_id").
+							Order("name DESC").
+							Frame("ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW")
+					})
+			},
+		},
+		{
+			id: 174,
+			query: func(db *bun.DB) schema.QueryAppender {
+				return db.NewSelect().
+					Model((*Story)(nil)).
+					Column("user_id").
+					ColumnExpr("count(*) ? AS num_hello", bun.Filter("name = ?", "hello")).
+					ColumnExpr("sum(id) ? AS running_sum", bun.OverWindow(func(w *bun.WindowDef) {
+						w.Partition("user_id").OrderExpr("id")
+					})).
+					Group("user_id", "id")
+			},
+		},
+		{
+			id: 175,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// LATERAL join with a correlated subquery
+				return db.NewSelect().
+					Model((*User)(nil)).
+					JoinLateral("last_story", db.NewSelect().
+						Model((*Story)(nil)).
+						Column("id", "name").
+						ColumnExpr("count(*) OVER () AS num_stories").
+						Where("story.user_id = user.id").
+						OrderExpr("story.id DESC").
+						Limit(1))
+			},
+		},
+		{
+			id: 176,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// JOIN with a subquery
+				return db.NewSelect().
+					Model((*User)(nil)).
+					JoinSubquery("JOIN", "stats", db.NewSelect().
+						Model((*Story)(nil)).
+						Column("user_id").
+						ColumnExpr("count(*) AS num_stories").
+						Group("user_id")).
+					JoinOn("stats.user_id = user.id")
+			},
+		},
+		{
+			id: 177,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// update with optimistic locking
+				return db.NewUpdate().Model(&Versioned{ID: 1, Name: "hello", Version: 3}).WherePK()
+			},
+		},
+		{
+			id: 178,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// delete with optimistic locking
+				return db.NewDelete().Model(&Versioned{ID: 1, Version: 3}).WherePK()
+			},
+		},
+		{
+			id: 179,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// bulk update with optimistic locking
+				return db.NewUpdate().Model(&[]Versioned{
+					{ID: 1, Name: "hello", Version: 3},
+					{ID: 2, Name: "world", Version: 5},
+				}).Bulk()
+			},
+		},
+		{
+			id: 180,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// formatting an insert doesn't set created_at and updated_at
+				return db.WithClock(clock).NewInsert().Model(&Timestamped{Name: "hello"})
+			},
+		},
+		{
+			id: 181,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// update refreshes updated_at even if it is not in the column list
+				return db.WithClock(clock).NewUpdate().
+					Model(&Timestamped{ID: 1, Name: "hello"}).
+					Column("name").
+					WherePK()
+			},
+		},
+		{
+			id: 182,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// Set-based update refreshes updated_at
+				return db.WithClock(clock).NewUpdate().
+					Model((*Timestamped)(nil)).
+					Set("name = ?", "hello").
+					Where("id = 1")
+			},
+		},
+		{
+			id: 183,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// select with boolean soft delete
+				return db.NewSelect().Model((*SoftDeleteBool)(nil))
+			},
+		},
+		{
+			id: 184,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// boolean soft delete with extra columns
+				return db.NewDelete().
+					Model(&SoftDeleteBool{ID: 1}).
+					WherePK().
+					Set("deleted_by = ?", "admin")
+			},
+		},
+		{
+			id: 185,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// restore soft deleted row
+				return db.NewRestore().Model(&SoftDelete1{ID: 1}).WherePK()
+			},
+		},
+		{
+			id: 186,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// restore boolean soft deleted rows
+				return db.NewRestore().
+					Model(&[]SoftDeleteBool{{ID: 1}, {ID: 2}}).
+					WherePK().
+					Set("deleted_by = NULL")
+			},
+		},
+		{
+			id: 187,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// restore soft deleted row with a non-nullable timestamp
+				return db.NewRestore().Model((*SoftDelete2)(nil)).Where("id = 1")
+			},
+		},
+		{
+			id: 188,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// select with default scopes
+				return db.NewSelect().Model((*TenantProject)(nil)).Where("id = 1").WhereOr("id = 2")
+			},
+		},
+		{
+			id: 189,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// update with a disabled default scope
+				return db.NewUpdate().
+					Model(&TenantProject{ID: 1, Name: "hello"}).
+					WherePK().
+					WithoutScope("active")
+			},
+		},
+		{
+			id: 190,
+			query: func(db *bun.DB) schema.QueryAppender {
+				// delete with default scopes
+				return db.NewDelete().Model((*TenantProject)(nil)).Where("id = 1")
+			},
+		},
 	}
 
 	timeRE := regexp.MustCompile(`'2\d{3}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(\.\d+)?(\+\d{2}:\d{2})?'`)
@@ -0,0 +1,149 @@
+package dbtest_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"github.com/uptrace/bun/extra/bunexplain"
+)
+
+type ReplicaModel struct {
+	ID   int64 `bun:",pk"`
+	Name string
+}
+
+func openReplicaTestDB(t *testing.T, name string) *sql.DB {
+	sqldb, err := sql.Open(sqliteshim.DriverName(), filepath.Join(t.TempDir(), name+".db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqldb.Close() })
+
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	mustResetModel(t, ctx, db, (*ReplicaModel)(nil))
+	_, err = db.NewInsert().Model(&ReplicaModel{ID: 1, Name: name}).Exec(ctx)
+	require.NoError(t, err)
+
+	return sqldb
+}
+
+func selectReplicaName(t *testing.T, ctx context.Context, q *bun.SelectQuery) string {
+	var name string
+	err := q.Model((*ReplicaModel)(nil)).Column("name").Where("id = 1").Scan(ctx, &name)
+	require.NoError(t, err)
+	return name
+}
+
+func TestReplicas(t *testing.T) {
+	primary := openReplicaTestDB(t, "primary")
+	replica := openReplicaTestDB(t, "replica")
+
+	db := bun.NewDB(primary, sqlitedialect.New(), bun.WithReplicas(nil, replica))
+
+	require.Equal(t, "replica", selectReplicaName(t, ctx, db.NewSelect()))
+	require.Equal(t, "primary", selectReplicaName(t, ctx, db.NewSelect().UsePrimary()))
+	require.Equal(t, "primary", selectReplicaName(t, db.WithPrimary(ctx), db.NewSelect()))
+
+	count, err := db.NewSelect().Model((*ReplicaModel)(nil)).Where("name = 'replica'").Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		require.Equal(t, "primary", selectReplicaName(t, ctx, tx.NewSelect()))
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = db.NewUpdate().
+		Model((*ReplicaModel)(nil)).
+		Set("name = ?", "updated").
+		Where("id = 1").
+		Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "updated", selectReplicaName(t, ctx, db.NewSelect().UsePrimary()))
+	require.Equal(t, "replica", selectReplicaName(t, ctx, db.NewSelect()))
+}
+
+func TestReplicasPolicy(t *testing.T) {
+	primary := openReplicaTestDB(t, "primary")
+	replica1 := openReplicaTestDB(t, "replica1")
+	replica2 := openReplicaTestDB(t, "replica2")
+
+	db := bun.NewDB(primary, sqlitedialect.New(),
+		bun.WithReplicas(bun.RoundRobinReplicas(), replica1, replica2))
+
+	require.Equal(t, "replica1", selectReplicaName(t, ctx, db.NewSelect()))
+	require.Equal(t, "replica2", selectReplicaName(t, ctx, db.NewSelect()))
+	require.Equal(t, "replica1", selectReplicaName(t, ctx, db.NewSelect()))
+
+	db = bun.NewDB(primary, sqlitedialect.New(),
+		bun.WithReplicas(func(ctx context.Context, replicas []*sql.DB) *sql.DB {
+			return nil
+		}, replica1, replica2))
+	require.Equal(t, "primary", selectReplicaName(t, ctx, db.NewSelect()))
+}
+
+type badConnector struct{}
+
+func (badConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func (badConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestReplicasFailover(t *testing.T) {
+	primary := openReplicaTestDB(t, "primary")
+	broken := sql.OpenDB(badConnector{})
+	replica := openReplicaTestDB(t, "replica")
+
+	var picked []*sql.DB
+	db := bun.NewDB(primary, sqlitedialect.New(),
+		bun.WithReplicas(func(ctx context.Context, replicas []*sql.DB) *sql.DB {
+			picked = append(picked, replicas[0])
+			return replicas[0]
+		}, broken, replica))
+
+	require.Equal(t, "primary", selectReplicaName(t, ctx, db.NewSelect()))
+	require.Equal(t, "replica", selectReplicaName(t, ctx, db.NewSelect()))
+	require.Equal(t, []*sql.DB{broken, replica}, picked)
+}
+
+func TestReplicasExplain(t *testing.T) {
+	primary := openReplicaTestDB(t, "primary")
+	replica := openReplicaTestDB(t, "replica")
+
+	_, err := replica.Exec("CREATE TABLE replica_only (id INTEGER)")
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	db := bun.NewDB(primary, sqlitedialect.New(), bun.WithReplicas(nil, replica))
+	db.AddQueryHook(bunexplain.NewQueryHook(
+		bunexplain.WithSlowQueryThreshold(0),
+		bunexplain.WithHandler(func(
+			ctx context.Context, event *bun.QueryEvent, plan string, err error,
+		) {
+			errs <- err
+		}),
+	))
+
+	var count int
+	err = db.NewSelect().Table("replica_only").ColumnExpr("count(*)").Scan(ctx, &count)
+	require.NoError(t, err)
+
+	// The query is explained on the replica that executed it.
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the query is not explained")
+	}
+}
@@ -0,0 +1,167 @@
+package dbtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/bun"
+)
+
+func TestScopes(t *testing.T) {
+	type Test struct {
+		run func(t *testing.T, db *bun.DB)
+	}
+
+	tests := []Test{
+		{run: testScopesSelect},
+		{run: testScopesUpdateDelete},
+		{run: testScopesRelation},
+	}
+	testEachDB(t, func(t *testing.T, dbName string, db *bun.DB) {
+		for _, test := range tests {
+			t.Run(funcName(test.run), func(t *testing.T) {
+				test.run(t, db)
+			})
+		}
+	})
+}
+
+type TenantProject struct {
+	ID       int64 `bun:",pk"`
+	TenantID int64
+	Name     string
+	Archived bool
+	Tasks    []*TenantTask `bun:"rel:has-many,join:id=project_id"`
+}
+
+var _ bun.DefaultScoper = (*TenantProject)(nil)
+
+func (*TenantProject) DefaultScopes() map[string]func(bun.QueryBuilder) bun.QueryBuilder {
+	return map[string]func(bun.QueryBuilder) bun.QueryBuilder{
+		"tenant": func(q bun.QueryBuilder) bun.QueryBuilder {
+			return q.Where("tenant_id = ?", 1)
+		},
+		"active": func(q bun.QueryBuilder) bun.QueryBuilder {
+			return q.Where("archived = ?", false)
+		},
+	}
+}
+
+type TenantTask struct {
+	ID        int64 `bun:",pk"`
+	TenantID  int64
+	ProjectID int64
+	Name      string
+}
+
+func (*TenantTask) DefaultScopes() map[string]func(bun.QueryBuilder) bun.QueryBuilder {
+	return map[string]func(bun.QueryBuilder) bun.QueryBuilder{
+		"tenant": func(q bun.QueryBuilder) bun.QueryBuilder {
+			return q.Where("tenant_id = ?", 1)
+		},
+	}
+}
+
+func insertTenantProjects(t *testing.T, ctx context.Context, db *bun.DB) {
+	mustResetModel(t, ctx, db, (*TenantProject)(nil), (*TenantTask)(nil))
+
+	projects := []TenantProject{
+		{ID: 1, TenantID: 1, Name: "project1"},
+		{ID: 2, TenantID: 2, Name: "project2"},
+		{ID: 3, TenantID: 1, Name: "project3", Archived: true},
+	}
+	_, err := db.NewInsert().Model(&projects).Exec(ctx)
+	require.NoError(t, err)
+
+	tasks := []TenantTask{
+		{ID: 1, TenantID: 1, ProjectID: 1, Name: "task1"},
+		{ID: 2, TenantID: 2, ProjectID: 1, Name: "task2"},
+	}
+	_, err = db.NewInsert().Model(&tasks).Exec(ctx)
+	require.NoError(t, err)
+}
+
+func testScopesSelect(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	insertTenantProjects(t, ctx, db)
+
+	selectNames := func(t *testing.T, q *bun.SelectQuery) []string {
+		var names []string
+		err := q.Model((*TenantProject)(nil)).Column("name").OrderExpr("id").Scan(ctx, &names)
+		require.NoError(t, err)
+		return names
+	}
+
+	require.Equal(t, []string{"project1"}, selectNames(t, db.NewSelect()))
+	require.Equal(t, []string{"project1", "project3"},
+		selectNames(t, db.NewSelect().WithoutScope("active")))
+	require.Equal(t, []string{"project1", "project2", "project3"},
+		selectNames(t, db.NewSelect().WithoutScope("active", "tenant")))
+
+	// User conditions are grouped so they can't bypass the scopes.
+	require.Equal(t, []string{"project1"},
+		selectNames(t, db.NewSelect().Where("id = 2").WhereOr("id = 1")))
+
+	count, err := db.NewSelect().Model((*TenantProject)(nil)).Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func testScopesUpdateDelete(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	insertTenantProjects(t, ctx, db)
+
+	res, err := db.NewUpdate().
+		Model((*TenantProject)(nil)).
+		Set("name = ?", "updated").
+		Where("1 = 1").
+		Exec(ctx)
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	res, err = db.NewDelete().
+		Model((*TenantProject)(nil)).
+		Where("id IN (?)", bun.In([]int64{1, 2, 3})).
+		WithoutScope("active").
+		Exec(ctx)
+	require.NoError(t, err)
+	n, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	var names []string
+	err = db.NewSelect().
+		Model((*TenantProject)(nil)).
+		Column("name").
+		WithoutScope("tenant", "active").
+		Scan(ctx, &names)
+	require.NoError(t, err)
+	require.Equal(t, []string{"project2"}, names)
+}
+
+func testScopesRelation(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	insertTenantProjects(t, ctx, db)
+
+	project := new(TenantProject)
+	err := db.NewSelect().Model(project).Relation("Tasks").Where("id = 1").Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, project.Tasks, 1)
+	require.Equal(t, "task1", project.Tasks[0].Name)
+
+	project = new(TenantProject)
+	err = db.NewSelect().
+		Model(project).
+		Relation("Tasks", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.OrderExpr("id")
+		}).
+		Where("id = 1").
+		WithoutScope("tenant").
+		Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, project.Tasks, 2)
+}
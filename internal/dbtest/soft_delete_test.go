@@ -9,6 +9,7 @@ import (
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
 )
 
 func TestSoftDelete(t *testing.T) {
@@ -21,6 +22,9 @@ func TestSoftDelete(t *testing.T) {
 		{run: testSoftDeleteAPI},
 		{run: testSoftDeleteBulk},
 		{run: testSoftDeleteForce},
+		{run: testSoftDeleteBool},
+		{run: testSoftDeleteMarker},
+		{run: testSoftDeleteRestore},
 	}
 	testEachDB(t, func(t *testing.T, dbName string, db *bun.DB) {
 		for _, test := range tests {
@@ -179,3 +183,166 @@ func testSoftDeleteBulk(t *testing.T, db *bun.DB) {
 	require.NoError(t, err)
 	require.Equal(t, 0, count)
 }
+
+type LegacyVideo struct {
+	ID        int64 `bun:",pk,autoincrement"`
+	Name      string
+	IsDeleted bool `bun:",soft_delete,notnull,default:false"`
+	DeletedBy string
+}
+
+var _ bun.BeforeRestoreHook = (*LegacyVideo)(nil)
+
+func (*LegacyVideo) BeforeRestore(ctx context.Context, q *bun.RestoreQuery) error {
+	q.Set("deleted_by = ''")
+	return nil
+}
+
+func testSoftDeleteBool(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	mustResetModel(t, ctx, db, (*LegacyVideo)(nil))
+
+	videos := []LegacyVideo{{Name: "video1"}, {Name: "video2"}}
+	_, err := db.NewInsert().Model(&videos).Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewDelete().
+		Model(&videos[0]).
+		WherePK().
+		Set("deleted_by = ?", "admin").
+		Exec(ctx)
+	require.NoError(t, err)
+	require.True(t, videos[0].IsDeleted)
+
+	var names []string
+	err = db.NewSelect().Model((*LegacyVideo)(nil)).Column("name").Scan(ctx, &names)
+	require.NoError(t, err)
+	require.Equal(t, []string{"video2"}, names)
+
+	deleted := new(LegacyVideo)
+	err = db.NewSelect().Model(deleted).WhereDeleted().Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "video1", deleted.Name)
+	require.True(t, deleted.IsDeleted)
+	require.Equal(t, "admin", deleted.DeletedBy)
+
+	count, err := db.NewSelect().Model((*LegacyVideo)(nil)).WhereAllWithDeleted().Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+// VideoStatus is a soft delete marker that uses a status column.
+type VideoStatus string
+
+var _ schema.SoftDeleteMarker = VideoStatus("")
+
+func (VideoStatus) SoftDeleteValue(tm time.Time) interface{} {
+	return "deleted"
+}
+
+func (VideoStatus) AppendSoftDeleteWhere(
+	fmter schema.Formatter, b []byte, column schema.Safe, deleted bool,
+) []byte {
+	if deleted {
+		return fmter.AppendQuery(b, "? = 'deleted'", column)
+	}
+	return fmter.AppendQuery(b, "? != 'deleted'", column)
+}
+
+func (VideoStatus) SoftRestoreValue() interface{} {
+	return "active"
+}
+
+type StatusVideo struct {
+	ID     int64 `bun:",pk,autoincrement"`
+	Name   string
+	Status VideoStatus `bun:",soft_delete,notnull,default:'active'"`
+}
+
+func testSoftDeleteMarker(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	mustResetModel(t, ctx, db, (*StatusVideo)(nil))
+
+	videos := []StatusVideo{
+		{Name: "video1", Status: "active"},
+		{Name: "video2", Status: "draft"},
+	}
+	_, err := db.NewInsert().Model(&videos).Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewDelete().Model(&videos[1]).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, VideoStatus("deleted"), videos[1].Status)
+
+	var names []string
+	err = db.NewSelect().Model((*StatusVideo)(nil)).Column("name").Scan(ctx, &names)
+	require.NoError(t, err)
+	require.Equal(t, []string{"video1"}, names)
+
+	count, err := db.NewSelect().Model((*StatusVideo)(nil)).WhereDeleted().Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func testSoftDeleteRestore(t *testing.T, db *bun.DB) {
+	ctx := context.Background()
+	mustResetModel(t, ctx, db, (*Video)(nil), (*LegacyVideo)(nil), (*StatusVideo)(nil))
+
+	countVisible := func(t *testing.T, model interface{}) int {
+		count, err := db.NewSelect().Model(model).Count(ctx)
+		require.NoError(t, err)
+		return count
+	}
+
+	videos := []Video{{Name: "video1"}, {Name: "video2"}}
+	_, err := db.NewInsert().Model(&videos).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewDelete().Model(&videos).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, countVisible(t, (*Video)(nil)))
+
+	// Formatting the query doesn't restore the model.
+	_ = db.NewRestore().Model(&videos[0]).WherePK().String()
+	require.False(t, videos[0].DeletedAt.IsZero())
+
+	_, err = db.NewRestore().Model(&videos[0]).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.True(t, videos[0].DeletedAt.IsZero())
+	require.Equal(t, 1, countVisible(t, (*Video)(nil)))
+
+	_, err = db.NewRestore().Model(&videos).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, countVisible(t, (*Video)(nil)))
+
+	legacy := &LegacyVideo{Name: "video1"}
+	_, err = db.NewInsert().Model(legacy).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewDelete().Model(legacy).WherePK().Set("deleted_by = ?", "admin").Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewRestore().Model(legacy).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	require.False(t, legacy.IsDeleted)
+
+	restored := new(LegacyVideo)
+	err = db.NewSelect().Model(restored).Where("id = ?", legacy.ID).Scan(ctx)
+	require.NoError(t, err)
+	require.False(t, restored.IsDeleted)
+	require.Equal(t, "", restored.DeletedBy)
+
+	status := &StatusVideo{Name: "video1", Status: "draft"}
+	_, err = db.NewInsert().Model(status).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewDelete().Model(status).WherePK().Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewRestore().Model((*StatusVideo)(nil)).Where("name = ?", "video1").Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, countVisible(t, (*StatusVideo)(nil)))
+
+	type NotSoftDeleted struct {
+		ID int64 `bun:",pk,autoincrement"`
+	}
+	_, err = db.NewRestore().Model((*NotSoftDeleted)(nil)).Where("1 = 1").Exec(ctx)
+	require.Error(t, err)
+}
@@ -0,0 +1,408 @@
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// M2M manages rows of the join table of a many-to-many relation.
+//
+// Methods accept related models (for example, *Tag) or join models
+// (for example, *PostTag), which allows to set extra columns on the join rows.
+// Slices of models are accepted as well.
+type M2M struct {
+	db   *DB
+	conn IConn
+
+	strct reflect.Value
+	rel   *schema.Relation
+
+	err error
+}
+
+func NewM2M(db *DB, model interface{}, name string) *M2M {
+	m := &M2M{
+		db:   db,
+		conn: db.DB,
+	}
+
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		m.err = fmt.Errorf("bun: M2M(unsupported %T), expected a pointer to a struct", model)
+		return m
+	}
+	m.strct = v.Elem()
+
+	table := db.Table(m.strct.Type())
+	rel, ok := table.Relations[name]
+	if !ok {
+		m.err = fmt.Errorf("%s does not have relation=%q", table, name)
+		return m
+	}
+	if rel.Type != schema.ManyToManyRelation {
+		m.err = fmt.Errorf("bun: %s is not a many-to-many relation", rel)
+		return m
+	}
+	m.rel = rel
+
+	return m
+}
+
+func (m *M2M) Conn(db IConn) *M2M {
+	m.conn = db
+	return m
+}
+
+// Attach inserts the missing join rows. Existing join rows are left unchanged.
+// The join rows are selected and inserted in a transaction, or in a savepoint
+// when the M2M is already executed in a transaction.
+func (m *M2M) Attach(ctx context.Context, rows ...interface{}) error {
+	joinRows, err := m.joinRows(rows)
+	if err != nil {
+		return err
+	}
+
+	return m.runInTx(ctx, func(ctx context.Context) error {
+		existing, err := m.selectJoinRows(ctx)
+		if err != nil {
+			return err
+		}
+
+		var inserts []m2mRow
+		for _, row := range joinRows {
+			if _, ok := existing[row.key]; !ok {
+				inserts = append(inserts, row)
+			}
+		}
+		return m.insert(ctx, inserts)
+	})
+}
+
+// Detach deletes the join rows.
+func (m *M2M) Detach(ctx context.Context, rows ...interface{}) error {
+	joinRows, err := m.joinRows(rows)
+	if err != nil {
+		return err
+	}
+	return m.delete(ctx, joinRows)
+}
+
+// Sync makes the join rows match the rows by inserting the missing join rows
+// and deleting the rest. Extra columns of existing join rows are updated
+// only when join models are passed and the values differ.
+// Like Attach, Sync is executed in a transaction.
+func (m *M2M) Sync(ctx context.Context, rows ...interface{}) error {
+	joinRows, err := m.joinRows(rows)
+	if err != nil {
+		return err
+	}
+
+	return m.runInTx(ctx, func(ctx context.Context) error {
+		existing, err := m.selectJoinRows(ctx)
+		if err != nil {
+			return err
+		}
+
+		keep := make(map[string]struct{}, len(joinRows))
+		var inserts []m2mRow
+		for _, row := range joinRows {
+			keep[row.key] = struct{}{}
+
+			old, ok := existing[row.key]
+			if !ok {
+				inserts = append(inserts, row)
+				continue
+			}
+			if row.isJoinModel && !m.sameExtraColumns(old.strct, row.strct) {
+				if err := m.update(ctx, row); err != nil {
+					return err
+				}
+			}
+		}
+
+		var deletes []m2mRow
+		for key, row := range existing {
+			if _, ok := keep[key]; !ok {
+				deletes = append(deletes, row)
+			}
+		}
+
+		if err := m.delete(ctx, deletes); err != nil {
+			return err
+		}
+		return m.insert(ctx, inserts)
+	})
+}
+
+//------------------------------------------------------------------------------
+
+type m2mRow struct {
+	strct       reflect.Value // join model
+	key         string
+	isJoinModel bool
+}
+
+func (m *M2M) joinRows(rows []interface{}) ([]m2mRow, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	var joinRows []m2mRow
+	seen := make(map[string]struct{})
+
+	var add func(v reflect.Value) error
+	add = func(v reflect.Value) error {
+		v = indirect(v)
+
+		switch v.Type() {
+		case m.rel.M2MTable.Type:
+			strct := reflect.New(v.Type())
+			strct.Elem().Set(v)
+			if err := m.copyBasePKs(strct.Elem()); err != nil {
+				return err
+			}
+			return m.addRow(&joinRows, seen, m2mRow{strct: strct.Elem(), isJoinModel: true})
+		case m.rel.JoinTable.Type:
+			strct := reflect.New(m.rel.M2MTable.Type).Elem()
+			if err := m.copyBasePKs(strct); err != nil {
+				return err
+			}
+			for i, f := range m.rel.M2MJoinPKs {
+				if err := copyFieldValue(f.Value(strct), m.rel.JoinPKs[i].Value(v)); err != nil {
+					return err
+				}
+			}
+			return m.addRow(&joinRows, seen, m2mRow{strct: strct})
+		}
+
+		if v.Kind() == reflect.Slice {
+			for i := 0; i < v.Len(); i++ {
+				if err := add(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		return fmt.Errorf("bun: M2M %s got %s, expected %s or %s",
+			m.rel, v.Type(), m.rel.JoinTable.Type, m.rel.M2MTable.Type)
+	}
+
+	for _, row := range rows {
+		if err := add(reflect.ValueOf(row)); err != nil {
+			return nil, err
+		}
+	}
+	return joinRows, nil
+}
+
+func (m *M2M) addRow(rows *[]m2mRow, seen map[string]struct{}, row m2mRow) error {
+	row.key = m.rowKey(row.strct)
+	if _, ok := seen[row.key]; ok {
+		return nil
+	}
+	seen[row.key] = struct{}{}
+	*rows = append(*rows, row)
+	return nil
+}
+
+func (m *M2M) copyBasePKs(strct reflect.Value) error {
+	for i, f := range m.rel.M2MBasePKs {
+		if err := copyFieldValue(f.Value(strct), m.rel.BasePKs[i].Value(m.strct)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *M2M) rowKey(strct reflect.Value) string {
+	var b []byte
+	for _, f := range m.rel.M2MJoinPKs {
+		b = f.AppendValue(m.db.fmter, b, strct)
+		b = append(b, ',')
+	}
+	return string(b)
+}
+
+func (m *M2M) sameExtraColumns(a, b reflect.Value) bool {
+	for _, f := range m.extraFields() {
+		if string(f.AppendValue(m.db.fmter, nil, a)) != string(f.AppendValue(m.db.fmter, nil, b)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *M2M) extraFields() []*schema.Field {
+	var fields []*schema.Field
+	for _, f := range m.rel.M2MTable.Fields {
+		if !m.isKeyField(f) && !f.Tag.HasOption("scanonly") {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func (m *M2M) isKeyField(field *schema.Field) bool {
+	for _, f := range m.rel.M2MBasePKs {
+		if f == field {
+			return true
+		}
+	}
+	for _, f := range m.rel.M2MJoinPKs {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
+
+// runInTx runs fn in a transaction, or in a savepoint when the M2M is already
+// executed in a transaction, so the join rows are read and modified atomically.
+// The M2M is executed on the transaction while fn runs.
+func (m *M2M) runInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var begin func(ctx context.Context) (Tx, error)
+	switch conn := m.conn.(type) {
+	case *DB:
+		begin = func(ctx context.Context) (Tx, error) {
+			return conn.BeginTx(ctx, nil)
+		}
+	case *sql.DB:
+		if conn != m.db.DB {
+			return fn(ctx)
+		}
+		begin = func(ctx context.Context) (Tx, error) {
+			return m.db.BeginTx(ctx, nil)
+		}
+	case Conn:
+		begin = func(ctx context.Context) (Tx, error) {
+			return conn.BeginTx(ctx, nil)
+		}
+	case *sql.Conn:
+		begin = func(ctx context.Context) (Tx, error) {
+			return Conn{db: m.db, Conn: conn}.BeginTx(ctx, nil)
+		}
+	case Tx:
+		begin = func(ctx context.Context) (Tx, error) {
+			return conn.BeginTx(ctx, nil)
+		}
+	case *sql.Tx:
+		begin = func(ctx context.Context) (Tx, error) {
+			return Tx{ctx: ctx, db: m.db, Tx: conn}.BeginTx(ctx, nil)
+		}
+	default:
+		return fn(ctx)
+	}
+
+	conn := m.conn
+	defer func() {
+		m.conn = conn
+	}()
+
+	return runTxOnce(ctx, begin, func(ctx context.Context, tx Tx) error {
+		m.conn = tx
+		return fn(ctx)
+	})
+}
+
+// selectJoinRows selects the join rows of the model. The rows are locked
+// with FOR UPDATE when the dialect supports it, so concurrent M2M operations
+// wait for each other instead of overwriting the changes.
+func (m *M2M) selectJoinRows(ctx context.Context) (map[string]m2mRow, error) {
+	slice := reflect.New(reflect.SliceOf(reflect.PtrTo(m.rel.M2MTable.Type)))
+
+	q := m.db.NewSelect().Conn(m.conn).Model(slice.Interface())
+	m.whereBase(q.addWhere)
+	switch m.db.dialect.Name() {
+	case dialect.SQLite, dialect.MSSQL:
+	default:
+		q = q.For("UPDATE")
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	slice = slice.Elem()
+	rows := make(map[string]m2mRow, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		strct := slice.Index(i).Elem()
+		key := m.rowKey(strct)
+		rows[key] = m2mRow{strct: strct, key: key}
+	}
+	return rows, nil
+}
+
+func (m *M2M) insert(ctx context.Context, rows []m2mRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	slice := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(m.rel.M2MTable.Type)), 0, len(rows))
+	for _, row := range rows {
+		slice = reflect.Append(slice, row.strct.Addr())
+	}
+
+	_, err := m.db.NewInsert().
+		Conn(m.conn).
+		Model(slicePtr(slice)).
+		Exec(ctx)
+	return err
+}
+
+func (m *M2M) update(ctx context.Context, row m2mRow) error {
+	q := m.db.NewUpdate().
+		Conn(m.conn).
+		Model(row.strct.Addr().Interface())
+	for _, f := range m.extraFields() {
+		q = q.Column(f.Name)
+	}
+	m.whereBase(q.addWhere)
+	m.whereJoin(q.addWhere, row.strct)
+	_, err := q.Exec(ctx)
+	return err
+}
+
+func (m *M2M) delete(ctx context.Context, rows []m2mRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	q := m.db.NewDelete().
+		Conn(m.conn).
+		Model(reflect.New(m.rel.M2MTable.Type).Interface())
+	m.whereBase(q.addWhere)
+	q = q.WhereGroup(" AND ", func(q *DeleteQuery) *DeleteQuery {
+		for _, row := range rows {
+			q = q.WhereGroup(" OR ", func(q *DeleteQuery) *DeleteQuery {
+				m.whereJoin(q.addWhere, row.strct)
+				return q
+			})
+		}
+		return q
+	})
+	_, err := q.Exec(ctx)
+	return err
+}
+
+func (m *M2M) whereBase(addWhere func(schema.QueryWithSep)) {
+	for i, f := range m.rel.M2MBasePKs {
+		addWhere(schema.SafeQueryWithSep("? = ?", []interface{}{
+			f.SQLName, Safe(m.rel.BasePKs[i].AppendValue(m.db.fmter, nil, m.strct)),
+		}, " AND "))
+	}
+}
+
+func (m *M2M) whereJoin(addWhere func(schema.QueryWithSep), strct reflect.Value) {
+	for _, f := range m.rel.M2MJoinPKs {
+		addWhere(schema.SafeQueryWithSep("? = ?", []interface{}{
+			f.SQLName, Safe(f.AppendValue(m.db.fmter, nil, strct)),
+		}, " AND "))
+	}
+}
@@ -84,6 +84,9 @@ func (m *hasManyModel) Scan(src interface{}) error {
 
 	field := m.table.LookupField(column)
 	if field == nil {
+		if column == rowNumberColumn {
+			return nil
+		}
 		return fmt.Errorf("bun: %s does not have column %q", m.table.TypeName, column)
 	}
 
@@ -6,6 +6,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +21,8 @@ const (
 	forceDeleteFlag internal.Flag = 1 << iota
 	deletedFlag
 	allWithDeletedFlag
+	versionFlag
+	usePrimaryFlag
 )
 
 type withQuery struct {
@@ -106,6 +109,11 @@ type baseQuery struct {
 	modelTableName schema.QueryWithArgs
 	tables         []schema.QueryWithArgs
 	columns        []schema.QueryWithArgs
+	comment        string
+
+	// now is the time set on the timestamp and soft delete fields of the model
+	// before the query is executed, so the database gets the same value.
+	now time.Time
 
 	flags internal.Flag
 }
@@ -163,6 +171,24 @@ func (q *baseQuery) setConn(db IConn) {
 	}
 }
 
+// resolveConn returns the connection to execute the query with.
+// Read-only queries on the DB are routed to a replica, see WithReplicas.
+func (q *baseQuery) resolveConn(ctx context.Context, query Query) IConn {
+	if q.db.replicas == nil || q.conn != IConn(q.db.DB) {
+		return q.conn
+	}
+	if rq, ok := query.(interface{ readOnly() bool }); !ok || !rq.readOnly() {
+		return q.conn
+	}
+	if primary, _ := ctx.Value(primaryCtxKey{}).(bool); primary {
+		return q.conn
+	}
+	if conn := q.db.replicas.conn(ctx); conn != nil {
+		return conn
+	}
+	return q.conn
+}
+
 func (q *baseQuery) setModel(modeli interface{}) {
 	model, err := newSingleModel(q.db, modeli)
 	if err != nil {
@@ -419,7 +445,7 @@ func (q *baseQuery) _appendTables(
 				return nil, err
 			}
 		} else {
-			b = fmter.AppendQuery(b, string(q.table.SQLNameForSelects))
+			b = q.table.AppendNameForSelects(fmter, b)
 			if withAlias && q.table.SQLAlias != q.table.SQLNameForSelects {
 				if q.db.dialect.Name() == dialect.Oracle {
 					b = append(b, ' ')
@@ -444,6 +470,15 @@ func (q *baseQuery) _appendTables(
 	return b, nil
 }
 
+// tableName appends the name of the table using the schema of the formatter.
+type tableName struct {
+	table *schema.Table
+}
+
+func (n tableName) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	return n.table.AppendName(fmter, b), nil
+}
+
 func (q *baseQuery) appendFirstTable(fmter schema.Formatter, b []byte) ([]byte, error) {
 	return q._appendFirstTable(fmter, b, false)
 }
@@ -462,7 +497,7 @@ func (q *baseQuery) _appendFirstTable(
 	}
 
 	if q.table != nil {
-		b = fmter.AppendQuery(b, string(q.table.SQLName))
+		b = q.table.AppendName(fmter, b)
 		if withAlias {
 			b = append(b, " AS "...)
 			b = append(b, q.table.SQLAlias...)
@@ -564,9 +599,11 @@ func (q *baseQuery) scan(
 	model Model,
 	hasDest bool,
 ) (sql.Result, error) {
-	ctx, event := q.db.beforeQuery(ctx, iquery, query, nil, query, q.model)
+	query = q.db.commentQuery(ctx, query)
+	conn := q.resolveConn(ctx, iquery)
+	ctx, event := q.db.beforeQuery(ctx, iquery, query, nil, query, q.model, conn)
 
-	rows, err := q.conn.QueryContext(ctx, query)
+	rows, err := conn.QueryContext(ctx, query)
 	if err != nil {
 		q.db.afterQuery(ctx, event, nil, err)
 		return nil, err
@@ -574,6 +611,9 @@ func (q *baseQuery) scan(
 	defer rows.Close()
 
 	numRow, err := model.ScanRows(ctx, rows)
+	if event != nil {
+		event.RowsScanned = numRow
+	}
 	if err != nil {
 		q.db.afterQuery(ctx, event, nil, err)
 		return nil, err
@@ -585,6 +625,9 @@ func (q *baseQuery) scan(
 
 	res := driver.RowsAffected(numRow)
 	q.db.afterQuery(ctx, event, res, err)
+	if err == nil {
+		q.db.invalidateCache(ctx, iquery, conn)
+	}
 
 	return res, err
 }
@@ -594,9 +637,14 @@ func (q *baseQuery) exec(
 	iquery Query,
 	query string,
 ) (sql.Result, error) {
-	ctx, event := q.db.beforeQuery(ctx, iquery, query, nil, query, q.model)
-	res, err := q.conn.ExecContext(ctx, query)
+	query = q.db.commentQuery(ctx, query)
+	conn := q.resolveConn(ctx, iquery)
+	ctx, event := q.db.beforeQuery(ctx, iquery, query, nil, query, q.model, conn)
+	res, err := conn.ExecContext(ctx, query)
 	q.db.afterQuery(ctx, event, res, err)
+	if err == nil {
+		q.db.invalidateCache(ctx, iquery, conn)
+	}
 	return res, err
 }
 
@@ -615,7 +663,7 @@ func (q *baseQuery) AppendNamedArg(fmter schema.Formatter, b []byte, name string
 
 	switch name {
 	case "TableName":
-		b = fmter.AppendQuery(b, string(q.table.SQLName))
+		b = q.table.AppendName(fmter, b)
 		return b, true
 	case "TableAlias":
 		b = fmter.AppendQuery(b, string(q.table.SQLAlias))
@@ -663,6 +711,10 @@ func (q *baseQuery) NewDelete() *DeleteQuery {
 	return NewDeleteQuery(q.db).Conn(q.conn)
 }
 
+func (q *baseQuery) NewRestore() *RestoreQuery {
+	return NewRestoreQuery(q.db).Conn(q.conn)
+}
+
 func (q *baseQuery) NewRaw(query string, args ...interface{}) *RawQuery {
 	return NewRawQuery(q.db, query, args...).Conn(q.conn)
 }
@@ -726,6 +778,12 @@ type whereBaseQuery struct {
 
 	where       []schema.QueryWithSep
 	whereFields []*schema.Field
+
+	scopeWhere    []schema.QueryWithSep
+	withoutScopes []string
+	// scopesCollected is set once the scopes are collected, so formatting
+	// the query again doesn't call the scope functions.
+	scopesCollected bool
 }
 
 func (q *whereBaseQuery) addWhere(where schema.QueryWithSep) {
@@ -791,7 +849,7 @@ func (q *whereBaseQuery) mustAppendWhere(
 func (q *whereBaseQuery) appendWhere(
 	fmter schema.Formatter, b []byte, withAlias bool,
 ) (_ []byte, err error) {
-	if len(q.where) == 0 && q.whereFields == nil && !q.isSoftDelete() {
+	if len(q.where) == 0 && q.whereFields == nil && len(q.scopeWhere) == 0 && !q.isSoftDelete() {
 		return b, nil
 	}
 
@@ -799,10 +857,27 @@ func (q *whereBaseQuery) appendWhere(
 	startLen := len(b)
 
 	if len(q.where) > 0 {
+		group := len(q.scopeWhere) > 0 && len(q.where) > 1
+		if group {
+			b = append(b, '(')
+		}
 		b, err = appendWhere(fmter, b, q.where)
 		if err != nil {
 			return nil, err
 		}
+		if group {
+			b = append(b, ')')
+		}
+	}
+
+	if len(q.scopeWhere) > 0 {
+		if len(b) > startLen {
+			b = append(b, " AND "...)
+		}
+		b, err = appendWhere(fmter, b, q.scopeWhere)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if q.isSoftDelete() {
@@ -810,37 +885,40 @@ func (q *whereBaseQuery) appendWhere(
 			b = append(b, " AND "...)
 		}
 
+		table := q.tableModel.Table()
+		column := make([]byte, 0, 32)
 		if withAlias {
-			b = append(b, q.tableModel.Table().SQLAlias...)
+			column = append(column, table.SQLAlias...)
 		} else {
-			b = append(b, q.tableModel.Table().SQLName...)
+			column = append(column, table.SQLName...)
 		}
-		b = append(b, '.')
+		column = append(column, '.')
+		column = append(column, table.SoftDeleteField.SQLName...)
 
-		field := q.tableModel.Table().SoftDeleteField
-		b = append(b, field.SQLName...)
+		b = table.SoftDelete.AppendSoftDeleteWhere(
+			fmter, b, schema.Safe(column), q.flags.Has(deletedFlag))
+	}
 
-		if field.IsPtr || field.NullZero {
-			if q.flags.Has(deletedFlag) {
-				b = append(b, " IS NOT NULL"...)
-			} else {
-				b = append(b, " IS NULL"...)
-			}
+	whereFields := q.whereFields
+	if field := q.versionField(); field != nil {
+		if whereFields != nil {
+			whereFields = append(whereFields[:len(whereFields):len(whereFields)], field)
 		} else {
-			if q.flags.Has(deletedFlag) {
-				b = append(b, " != "...)
-			} else {
-				b = append(b, " = "...)
+			if len(b) > startLen {
+				b = append(b, " AND "...)
+			}
+			b, err = q.appendWhereFields(fmter, b, []*schema.Field{field}, withAlias)
+			if err != nil {
+				return nil, err
 			}
-			b = fmter.Dialect().AppendTime(b, time.Time{})
 		}
 	}
 
-	if q.whereFields != nil {
+	if whereFields != nil {
 		if len(b) > startLen {
 			b = append(b, " AND "...)
 		}
-		b, err = q.appendWhereFields(fmter, b, q.whereFields, withAlias)
+		b, err = q.appendWhereFields(fmter, b, whereFields, withAlias)
 		if err != nil {
 			return nil, err
 		}
@@ -849,6 +927,61 @@ func (q *whereBaseQuery) appendWhere(
 	return b, nil
 }
 
+// checkVersion returns ErrStaleObject when the query did not affect every row of the model.
+// Otherwise, it increments the version field of the model when inc is true.
+func (q *whereBaseQuery) checkVersion(res sql.Result, field *schema.Field, inc bool) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	var rows []reflect.Value
+	walk(reflect.ValueOf(q.tableModel.Value()), nil, func(v reflect.Value) {
+		rows = append(rows, v)
+	})
+	if n < int64(len(rows)) {
+		return ErrStaleObject
+	}
+
+	if inc {
+		for _, strct := range rows {
+			fv := field.Value(strct)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			switch fv.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				fv.SetInt(fv.Int() + 1)
+			default:
+				fv.SetUint(fv.Uint() + 1)
+			}
+		}
+	}
+	return nil
+}
+
+// versionField returns the version field when the query must check it,
+// that is, Update and Delete queries on a struct or on a slice with WherePK.
+func (q *whereBaseQuery) versionField() *schema.Field {
+	if !q.flags.Has(versionFlag) || q.table == nil || q.table.VersionField == nil {
+		return nil
+	}
+	switch model := q.tableModel.(type) {
+	case *structTableModel:
+		if model.strct.IsValid() {
+			return q.table.VersionField
+		}
+	case *sliceTableModel:
+		if q.whereFields != nil {
+			return q.table.VersionField
+		}
+	}
+	return nil
+}
+
 func appendWhere(
 	fmter schema.Formatter, b []byte, where []schema.QueryWithSep,
 ) (_ []byte, err error) {
@@ -1365,6 +1498,10 @@ type orderLimitOffsetQuery struct {
 }
 
 func (q *orderLimitOffsetQuery) addOrder(orders ...string) {
+	q.order = appendOrder(q.order, orders...)
+}
+
+func appendOrder(dst []schema.QueryWithArgs, orders ...string) []schema.QueryWithArgs {
 	for _, order := range orders {
 		if order == "" {
 			continue
@@ -1372,7 +1509,7 @@ func (q *orderLimitOffsetQuery) addOrder(orders ...string) {
 
 		index := strings.IndexByte(order, ' ')
 		if index == -1 {
-			q.order = append(q.order, schema.UnsafeIdent(order))
+			dst = append(dst, schema.UnsafeIdent(order))
 			continue
 		}
 
@@ -1382,15 +1519,15 @@ func (q *orderLimitOffsetQuery) addOrder(orders ...string) {
 		switch strings.ToUpper(sort) {
 		case "ASC", "DESC", "ASC NULLS FIRST", "DESC NULLS FIRST",
 			"ASC NULLS LAST", "DESC NULLS LAST":
-			q.order = append(q.order, schema.SafeQuery("? ?", []interface{}{
+			dst = append(dst, schema.SafeQuery("? ?", []interface{}{
 				Ident(field),
 				Safe(sort),
 			}))
 		default:
-			q.order = append(q.order, schema.UnsafeIdent(order))
+			dst = append(dst, schema.UnsafeIdent(order))
 		}
 	}
-
+	return dst
 }
 
 func (q *orderLimitOffsetQuery) addOrderExpr(query string, args ...interface{}) {
@@ -15,6 +15,7 @@ type DeleteQuery struct {
 	whereBaseQuery
 	orderLimitOffsetQuery
 	returningQuery
+	setQuery
 }
 
 var _ Query = (*DeleteQuery)(nil)
@@ -23,8 +24,9 @@ func NewDeleteQuery(db *DB) *DeleteQuery {
 	q := &DeleteQuery{
 		whereBaseQuery: whereBaseQuery{
 			baseQuery: baseQuery{
-				db:   db,
-				conn: db.DB,
+				db:    db,
+				conn:  db.DB,
+				flags: versionFlag,
 			},
 		},
 	}
@@ -46,6 +48,13 @@ func (q *DeleteQuery) Err(err error) *DeleteQuery {
 	return q
 }
 
+// Comment adds the comment to the start of the query, for example,
+// to find the code that executed the query in the database logs.
+func (q *DeleteQuery) Comment(comment string) *DeleteQuery {
+	q.comment = comment
+	return q
+}
+
 // Apply calls each function in fns, passing the DeleteQuery as an argument.
 func (q *DeleteQuery) Apply(fns ...func(*DeleteQuery) *DeleteQuery) *DeleteQuery {
 	for _, fn := range fns {
@@ -124,6 +133,13 @@ func (q *DeleteQuery) WhereAllWithDeleted() *DeleteQuery {
 	return q
 }
 
+// WithoutScope disables the named default scopes of the model.
+// See DefaultScoper.
+func (q *DeleteQuery) WithoutScope(names ...string) *DeleteQuery {
+	q.withoutScope(names)
+	return q
+}
+
 func (q *DeleteQuery) Order(orders ...string) *DeleteQuery {
 	if !q.hasFeature(feature.DeleteOrderLimit) {
 		q.err = errors.New("bun: order is not supported for current dialect")
@@ -147,6 +163,14 @@ func (q *DeleteQuery) ForceDelete() *DeleteQuery {
 	return q
 }
 
+// Set adds a SET expression that is applied when rows are soft deleted,
+// for example, to record who deleted the rows. Hard deletes ignore it.
+// BeforeDeleteHook can use it to set values from the context.
+func (q *DeleteQuery) Set(query string, args ...interface{}) *DeleteQuery {
+	q.addSet(schema.SafeQuery(query, args))
+	return q
+}
+
 // ------------------------------------------------------------------------------
 func (q *DeleteQuery) Limit(n int) *DeleteQuery {
 	if !q.hasFeature(feature.DeleteOrderLimit) {
@@ -184,19 +208,15 @@ func (q *DeleteQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, e
 	}
 
 	fmter = formatterWithModel(fmter, q)
+	b = prependComment(b, q.comment)
 
 	if q.isSoftDelete() {
-		now := time.Now()
-
-		if err := q.tableModel.updateSoftDeleteField(now); err != nil {
-			return nil, err
-		}
-
 		upd := &UpdateQuery{
 			whereBaseQuery: q.whereBaseQuery,
 			returningQuery: q.returningQuery,
 		}
-		upd.Set(q.softDeleteSet(fmter, now))
+		upd.Set(q.softDeleteSet(fmter, q.timestamp()))
+		upd.set = append(upd.set, q.set...)
 
 		return upd.AppendQuery(fmter, b)
 	}
@@ -235,6 +255,10 @@ func (q *DeleteQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, e
 		}
 	}
 
+	if err := q.applyScopes(); err != nil {
+		return nil, err
+	}
+
 	b, err = q.mustAppendWhere(fmter, b, withAlias)
 	if err != nil {
 		return nil, err
@@ -277,7 +301,12 @@ func (q *DeleteQuery) softDeleteSet(fmter schema.Formatter, tm time.Time) string
 	}
 	b = append(b, q.table.SoftDeleteField.SQLName...)
 	b = append(b, " = "...)
-	b = schema.Append(fmter, b, tm)
+	switch v := q.table.SoftDelete.SoftDeleteValue(tm).(type) {
+	case bool:
+		b = fmter.Dialect().AppendBool(b, v)
+	default:
+		b = schema.Append(fmter, b, v)
+	}
 	return internal.String(b)
 }
 
@@ -292,6 +321,16 @@ func (q *DeleteQuery) Exec(ctx context.Context, dest ...interface{}) (sql.Result
 	return q.scanOrExec(ctx, dest, len(dest) > 0)
 }
 
+// Explain returns the plan of the query generated with the dialect EXPLAIN statement.
+// The statement is not executed unless opts.Analyze is set. Explain doesn't run
+// the model hooks and doesn't change the timestamp and soft delete fields of the model.
+func (q *DeleteQuery) Explain(ctx context.Context, opts *ExplainOptions) (*QueryPlan, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.explain(ctx, q, opts)
+}
+
 func (q *DeleteQuery) scanOrExec(
 	ctx context.Context, dest []interface{}, hasDest bool,
 ) (sql.Result, error) {
@@ -310,8 +349,18 @@ func (q *DeleteQuery) scanOrExec(
 		return nil, err
 	}
 
+	if q.isSoftDelete() {
+		q.now = q.db.now()
+		if err := q.tableModel.updateSoftDeleteField(q.now); err != nil {
+			return nil, err
+		}
+		if err := q.updateTimestamps(q.now, false); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate the query before checking hasReturning.
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -343,6 +392,12 @@ func (q *DeleteQuery) scanOrExec(
 		}
 	}
 
+	if field := q.versionField(); field != nil {
+		if err := q.checkVersion(res, field, q.isSoftDelete()); err != nil {
+			return nil, err
+		}
+	}
+
 	if q.table != nil {
 		if err := q.afterDeleteHook(ctx); err != nil {
 			return nil, err
@@ -381,6 +436,13 @@ func (q *DeleteQuery) String() string {
 
 //------------------------------------------------------------------------------
 
+func (q *DeleteQuery) applyScopes() error {
+	return q.collectScopes(func() (QueryBuilder, *whereBaseQuery) {
+		scoped := &DeleteQuery{whereBaseQuery: q.scopeQuery()}
+		return scoped.QueryBuilder(), &scoped.whereBaseQuery
+	})
+}
+
 func (q *DeleteQuery) QueryBuilder() QueryBuilder {
 	return &deleteQueryBuilder{q}
 }
@@ -0,0 +1,226 @@
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/internal"
+)
+
+// ExplainOptions configures the EXPLAIN statement generated by Explain.
+type ExplainOptions struct {
+	// Analyze executes the statement and reports the actual run time.
+	// It is supported by PostgreSQL and MySQL. Use a transaction that is rolled back
+	// to analyze update and delete queries without changing the data.
+	Analyze bool
+}
+
+// QueryPlan is the plan returned by EXPLAIN.
+type QueryPlan struct {
+	// Columns and Rows contain the output of EXPLAIN as is.
+	Columns []string
+	Rows    [][]string
+
+	// Nodes is the plan tree parsed from the PostgreSQL JSON output
+	// and the SQLite EXPLAIN QUERY PLAN output. It is nil for other dialects.
+	Nodes []*PlanNode
+}
+
+// PlanNode is a node of the query plan tree.
+type PlanNode struct {
+	// Name is the node type on PostgreSQL, for example, "Seq Scan",
+	// and the detail on SQLite, for example, "SCAN users".
+	Name string
+	// Props contains the rest of the node properties on PostgreSQL,
+	// for example, "Relation Name" and "Total Cost". The root nodes also
+	// contain the top-level properties such as "Planning Time".
+	Props map[string]interface{}
+
+	Children []*PlanNode
+}
+
+func (q *baseQuery) explain(
+	ctx context.Context, iquery Query, opts *ExplainOptions,
+) (*QueryPlan, error) {
+	if opts == nil {
+		opts = new(ExplainOptions)
+	}
+
+	name := q.db.Dialect().Name()
+	b, err := appendExplain(name, q.db.makeQueryBytes(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = iquery.AppendQuery(q.db.formatter(ctx), b)
+	if err != nil {
+		return nil, err
+	}
+
+	query := q.db.commentQuery(ctx, internal.String(b))
+
+	// The query is reported to hooks without the model, so its operation is EXPLAIN.
+	conn := q.resolveConn(ctx, iquery)
+	ctx, event := q.db.beforeQuery(ctx, nil, query, nil, query, nil, conn)
+	plan, err := queryPlan(ctx, conn, query)
+	if event != nil && plan != nil {
+		event.RowsScanned = len(plan.Rows)
+	}
+	q.db.afterQuery(ctx, event, nil, err)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case dialect.PG:
+		plan.Nodes, err = parsePGPlan(plan.Rows)
+	case dialect.SQLite:
+		plan.Nodes, err = parseSQLitePlan(plan.Rows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func queryPlan(ctx context.Context, conn IConn, query string) (*QueryPlan, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &QueryPlan{Columns: columns}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		plan.Rows = append(plan.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func appendExplain(name dialect.Name, b []byte, opts *ExplainOptions) ([]byte, error) {
+	switch name {
+	case dialect.PG:
+		if opts.Analyze {
+			return append(b, "EXPLAIN (ANALYZE, FORMAT JSON) "...), nil
+		}
+		return append(b, "EXPLAIN (FORMAT JSON) "...), nil
+	case dialect.MySQL:
+		if opts.Analyze {
+			return append(b, "EXPLAIN ANALYZE "...), nil
+		}
+		return append(b, "EXPLAIN FORMAT=JSON "...), nil
+	case dialect.SQLite:
+		if opts.Analyze {
+			return nil, fmt.Errorf("bun: EXPLAIN ANALYZE is not supported by %s", name)
+		}
+		return append(b, "EXPLAIN QUERY PLAN "...), nil
+	default:
+		return nil, fmt.Errorf("bun: EXPLAIN is not supported by %s", name)
+	}
+}
+
+// parsePGPlan parses the output of EXPLAIN (FORMAT JSON),
+// which is a single row with an array of plans.
+func parsePGPlan(rows [][]string) ([]*PlanNode, error) {
+	if len(rows) != 1 || len(rows[0]) != 1 {
+		return nil, fmt.Errorf("bun: can't parse EXPLAIN output with %d rows", len(rows))
+	}
+
+	var plans []map[string]interface{}
+	if err := json.Unmarshal([]byte(rows[0][0]), &plans); err != nil {
+		return nil, fmt.Errorf("bun: can't parse EXPLAIN output: %w", err)
+	}
+
+	nodes := make([]*PlanNode, 0, len(plans))
+	for _, plan := range plans {
+		m, _ := plan["Plan"].(map[string]interface{})
+		node := newPGPlanNode(m)
+		for k, v := range plan {
+			if k != "Plan" {
+				node.Props[k] = v
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func newPGPlanNode(m map[string]interface{}) *PlanNode {
+	node := &PlanNode{
+		Props: make(map[string]interface{}, len(m)),
+	}
+	for k, v := range m {
+		switch k {
+		case "Node Type":
+			node.Name, _ = v.(string)
+		case "Plans":
+			children, _ := v.([]interface{})
+			for _, child := range children {
+				if child, ok := child.(map[string]interface{}); ok {
+					node.Children = append(node.Children, newPGPlanNode(child))
+				}
+			}
+		default:
+			node.Props[k] = v
+		}
+	}
+	return node
+}
+
+// parseSQLitePlan parses the output of EXPLAIN QUERY PLAN,
+// which has the id, parent, notused, and detail columns.
+func parseSQLitePlan(rows [][]string) ([]*PlanNode, error) {
+	var roots []*PlanNode
+	nodes := make(map[int]*PlanNode, len(rows))
+
+	for _, row := range rows {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("bun: can't parse EXPLAIN QUERY PLAN output with %d columns", len(row))
+		}
+
+		id, err := strconv.Atoi(row[0])
+		if err != nil {
+			return nil, err
+		}
+		parent, err := strconv.Atoi(row[1])
+		if err != nil {
+			return nil, err
+		}
+
+		node := &PlanNode{Name: row[3]}
+		nodes[id] = node
+
+		if p, ok := nodes[parent]; ok {
+			p.Children = append(p.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
@@ -20,6 +20,8 @@ type InsertQuery struct {
 	on schema.QueryWithArgs
 	setQuery
 
+	relations []string
+
 	ignore  bool
 	replace bool
 }
@@ -53,6 +55,13 @@ func (q *InsertQuery) Err(err error) *InsertQuery {
 	return q
 }
 
+// Comment adds the comment to the start of the query, for example,
+// to find the code that executed the query in the database logs.
+func (q *InsertQuery) Comment(comment string) *InsertQuery {
+	q.comment = comment
+	return q
+}
+
 // Apply calls each function in fns, passing the InsertQuery as an argument.
 func (q *InsertQuery) Apply(fns ...func(*InsertQuery) *InsertQuery) *InsertQuery {
 	for _, fn := range fns {
@@ -162,6 +171,17 @@ func (q *InsertQuery) Replace() *InsertQuery {
 	return q
 }
 
+// WithRelations saves the named relations together with the model using the same IConn.
+// Belongs-to relations are saved first and their primary keys are copied into the model.
+// Has-one, has-many, and m2m relations are saved after the model using its primary keys,
+// and the missing m2m rows are inserted. Related rows with a zero primary key are inserted
+// and the rest are upserted. Use dots to save nested relations, e.g. "Comments.Author".
+// The queries are executed in a transaction unless the query is already executed in one.
+func (q *InsertQuery) WithRelations(names ...string) *InsertQuery {
+	q.relations = append(q.relations, names...)
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *InsertQuery) Operation() string {
@@ -174,6 +194,7 @@ func (q *InsertQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, e
 	}
 
 	fmter = formatterWithModel(fmter, q)
+	b = prependComment(b, q.comment)
 
 	b, err = q.appendWith(fmter, b)
 	if err != nil {
@@ -565,19 +586,46 @@ func (q *InsertQuery) scanOrExec(
 		return nil, q.err
 	}
 
+	if len(q.relations) == 0 || isTx(q.conn) {
+		return q._scanOrExec(ctx, dest, hasDest)
+	}
+
+	var res sql.Result
+	err := q.runInRelationsTx(ctx, func(ctx context.Context) (err error) {
+		res, err = q._scanOrExec(ctx, dest, hasDest)
+		return err
+	})
+	return res, err
+}
+
+func (q *InsertQuery) _scanOrExec(
+	ctx context.Context, dest []interface{}, hasDest bool,
+) (sql.Result, error) {
+
 	if q.table != nil {
 		if err := q.beforeInsertHook(ctx); err != nil {
 			return nil, err
 		}
 	}
 
+	if len(q.relations) > 0 {
+		if err := q.saveRelationsBefore(ctx, q.relations); err != nil {
+			return nil, err
+		}
+	}
+
 	// Run append model hooks before generating the query.
 	if err := q.beforeAppendModel(ctx, q); err != nil {
 		return nil, err
 	}
 
+	q.now = q.db.now()
+	if err := q.updateTimestamps(q.now, true); err != nil {
+		return nil, err
+	}
+
 	// Generate the query before checking hasReturning.
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -612,6 +660,12 @@ func (q *InsertQuery) scanOrExec(
 		}
 	}
 
+	if len(q.relations) > 0 {
+		if err := q.saveRelationsAfter(ctx, q.relations); err != nil {
+			return nil, err
+		}
+	}
+
 	if q.table != nil {
 		if err := q.afterInsertHook(ctx); err != nil {
 			return nil, err
@@ -50,6 +50,13 @@ func (q *MergeQuery) Err(err error) *MergeQuery {
 	return q
 }
 
+// Comment adds the comment to the start of the query, for example,
+// to find the code that executed the query in the database logs.
+func (q *MergeQuery) Comment(comment string) *MergeQuery {
+	q.comment = comment
+	return q
+}
+
 // Apply calls each function in fns, passing the MergeQuery as an argument.
 func (q *MergeQuery) Apply(fns ...func(*MergeQuery) *MergeQuery) *MergeQuery {
 	for _, fn := range fns {
@@ -160,6 +167,7 @@ func (q *MergeQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, er
 	}
 
 	fmter = formatterWithModel(fmter, q)
+	b = prependComment(b, q.comment)
 
 	b, err = q.appendWith(fmter, b)
 	if err != nil {
@@ -234,7 +242,7 @@ func (q *MergeQuery) scanOrExec(
 	}
 
 	// Generate the query before checking hasReturning.
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
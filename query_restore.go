@@ -0,0 +1,295 @@
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/internal"
+	"github.com/uptrace/bun/schema"
+)
+
+// RestoreQuery restores soft deleted rows by resetting the soft delete field.
+// It only affects rows that are soft deleted.
+type RestoreQuery struct {
+	whereBaseQuery
+	returningQuery
+	setQuery
+}
+
+var _ Query = (*RestoreQuery)(nil)
+
+func NewRestoreQuery(db *DB) *RestoreQuery {
+	q := &RestoreQuery{
+		whereBaseQuery: whereBaseQuery{
+			baseQuery: baseQuery{
+				db:   db,
+				conn: db.DB,
+			},
+		},
+	}
+	return q
+}
+
+func (q *RestoreQuery) Conn(db IConn) *RestoreQuery {
+	q.setConn(db)
+	return q
+}
+
+func (q *RestoreQuery) Model(model interface{}) *RestoreQuery {
+	q.setModel(model)
+	return q
+}
+
+func (q *RestoreQuery) Err(err error) *RestoreQuery {
+	q.setErr(err)
+	return q
+}
+
+// Apply calls each function in fns, passing the RestoreQuery as an argument.
+func (q *RestoreQuery) Apply(fns ...func(*RestoreQuery) *RestoreQuery) *RestoreQuery {
+	for _, fn := range fns {
+		if fn != nil {
+			q = fn(q)
+		}
+	}
+	return q
+}
+
+func (q *RestoreQuery) With(name string, query schema.QueryAppender) *RestoreQuery {
+	q.addWith(name, query, false)
+	return q
+}
+
+func (q *RestoreQuery) ModelTableExpr(query string, args ...interface{}) *RestoreQuery {
+	q.modelTableName = schema.SafeQuery(query, args)
+	return q
+}
+
+//------------------------------------------------------------------------------
+
+func (q *RestoreQuery) WherePK(cols ...string) *RestoreQuery {
+	q.addWhereCols(cols)
+	return q
+}
+
+func (q *RestoreQuery) Where(query string, args ...interface{}) *RestoreQuery {
+	q.addWhere(schema.SafeQueryWithSep(query, args, " AND "))
+	return q
+}
+
+func (q *RestoreQuery) WhereOr(query string, args ...interface{}) *RestoreQuery {
+	q.addWhere(schema.SafeQueryWithSep(query, args, " OR "))
+	return q
+}
+
+func (q *RestoreQuery) WhereGroup(sep string, fn func(*RestoreQuery) *RestoreQuery) *RestoreQuery {
+	saved := q.where
+	q.where = nil
+
+	q = fn(q)
+
+	where := q.where
+	q.where = saved
+
+	q.addWhereGroup(sep, where)
+
+	return q
+}
+
+// Set adds a SET expression that is applied together with the restore,
+// for example, to clear the column that records who deleted the rows.
+func (q *RestoreQuery) Set(query string, args ...interface{}) *RestoreQuery {
+	q.addSet(schema.SafeQuery(query, args))
+	return q
+}
+
+// Returning adds a RETURNING clause to the query.
+func (q *RestoreQuery) Returning(query string, args ...interface{}) *RestoreQuery {
+	q.addReturning(schema.SafeQuery(query, args))
+	return q
+}
+
+//------------------------------------------------------------------------------
+
+func (q *RestoreQuery) Operation() string {
+	return "UPDATE"
+}
+
+func (q *RestoreQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	if err := q.checkSoftDelete(); err != nil {
+		return nil, err
+	}
+
+	fmter = formatterWithModel(fmter, q)
+
+	upd := &UpdateQuery{
+		whereBaseQuery: q.whereBaseQuery,
+		returningQuery: q.returningQuery,
+	}
+	upd.flags = upd.flags.Set(deletedFlag).Remove(allWithDeletedFlag)
+	upd.Set(q.restoreSet(fmter))
+	upd.set = append(upd.set, q.set...)
+
+	return upd.AppendQuery(fmter, b)
+}
+
+// restoreValue returns the value that marks the row as not deleted.
+func (q *RestoreQuery) restoreValue() reflect.Value {
+	field := q.table.SoftDeleteField
+	if restorer, ok := q.table.SoftDelete.(schema.SoftDeleteRestorer); ok {
+		return reflect.ValueOf(restorer.SoftRestoreValue())
+	}
+	return reflect.Zero(field.StructField.Type)
+}
+
+func (q *RestoreQuery) restoreSet(fmter schema.Formatter) string {
+	field := q.table.SoftDeleteField
+
+	b := make([]byte, 0, 32)
+	if fmter.HasFeature(feature.UpdateMultiTable) {
+		b = append(b, q.table.SQLAlias...)
+		b = append(b, '.')
+	}
+	b = append(b, field.SQLName...)
+	b = append(b, " = "...)
+
+	v := q.restoreValue()
+	switch {
+	case v.Type() != field.StructField.Type:
+		b = schema.Append(fmter, b, v.Interface())
+	case field.IsPtr || field.NullZero:
+		b = append(b, "NULL"...)
+	default:
+		b = field.Append(fmter, b, v)
+	}
+	return internal.String(b)
+}
+
+func (q *RestoreQuery) updateSoftDeleteField() error {
+	field := q.table.SoftDeleteField
+	v := q.restoreValue()
+
+	var firstErr error
+	walk(reflect.ValueOf(q.tableModel.Value()), nil, func(strct reflect.Value) {
+		if firstErr != nil || !strct.IsValid() {
+			return
+		}
+		firstErr = copyFieldValue(field.Value(strct), v)
+	})
+	return firstErr
+}
+
+//------------------------------------------------------------------------------
+
+func (q *RestoreQuery) Scan(ctx context.Context, dest ...interface{}) error {
+	_, err := q.scanOrExec(ctx, dest, true)
+	return err
+}
+
+func (q *RestoreQuery) Exec(ctx context.Context, dest ...interface{}) (sql.Result, error) {
+	return q.scanOrExec(ctx, dest, len(dest) > 0)
+}
+
+func (q *RestoreQuery) scanOrExec(
+	ctx context.Context, dest []interface{}, hasDest bool,
+) (sql.Result, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	if q.table != nil {
+		if err := q.beforeRestoreHook(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Run append model hooks before generating the query.
+	if err := q.beforeAppendModel(ctx, q); err != nil {
+		return nil, err
+	}
+
+	if err := q.checkSoftDelete(); err != nil {
+		return nil, err
+	}
+	if err := q.updateSoftDeleteField(); err != nil {
+		return nil, err
+	}
+
+	q.now = q.db.now()
+	if err := q.updateTimestamps(q.now, false); err != nil {
+		return nil, err
+	}
+
+	// Generate the query before checking hasReturning.
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
+	if err != nil {
+		return nil, err
+	}
+
+	useScan := hasDest || (q.hasReturning() && q.hasFeature(feature.Returning|feature.Output))
+	var model Model
+
+	if useScan {
+		var err error
+		model, err = q.getModel(dest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := internal.String(queryBytes)
+
+	var res sql.Result
+
+	if useScan {
+		res, err = q.scan(ctx, q, query, model, hasDest)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		res, err = q.exec(ctx, q, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if q.table != nil {
+		if err := q.afterRestoreHook(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (q *RestoreQuery) beforeRestoreHook(ctx context.Context) error {
+	if hook, ok := q.table.ZeroIface.(BeforeRestoreHook); ok {
+		if err := hook.BeforeRestore(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *RestoreQuery) afterRestoreHook(ctx context.Context) error {
+	if hook, ok := q.table.ZeroIface.(AfterRestoreHook); ok {
+		if err := hook.AfterRestore(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *RestoreQuery) String() string {
+	buf, err := q.AppendQuery(q.db.Formatter(), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(buf)
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/uptrace/bun/dialect"
@@ -29,9 +32,17 @@ type SelectQuery struct {
 	joins      []joinQuery
 	group      []schema.QueryWithArgs
 	having     []schema.QueryWithArgs
+	windows    []namedWindow
 	selFor     schema.QueryWithArgs
 
+	relationAggs []schema.QueryWithArgs
+
+	// partition is set by relation queries to apply limit and offset per parent row.
+	partition schema.Safe
+
 	union []union
+
+	cache *queryCache
 }
 
 var _ Query = (*SelectQuery)(nil)
@@ -62,6 +73,13 @@ func (q *SelectQuery) Err(err error) *SelectQuery {
 	return q
 }
 
+// Comment adds the comment to the start of the query, for example,
+// to find the code that executed the query in the database logs.
+func (q *SelectQuery) Comment(comment string) *SelectQuery {
+	q.comment = comment
+	return q
+}
+
 // Apply calls each function in fns, passing the SelectQuery as an argument.
 func (q *SelectQuery) Apply(fns ...func(*SelectQuery) *SelectQuery) *SelectQuery {
 	for _, fn := range fns {
@@ -161,6 +179,77 @@ func (q *SelectQuery) WhereGroup(sep string, fn func(*SelectQuery) *SelectQuery)
 	return q
 }
 
+// WhereHas adds a correlated `EXISTS` condition that matches rows having at least one
+// related row. The name can be a nested relation path, for example, "Orders.Items",
+// and apply is used to filter the rows of the last relation in the path.
+func (q *SelectQuery) WhereHas(name string, apply ...func(*SelectQuery) *SelectQuery) *SelectQuery {
+	return q.whereHas("EXISTS", name, apply)
+}
+
+// WhereDoesntHave is like WhereHas, but matches rows that don't have related rows.
+func (q *SelectQuery) WhereDoesntHave(
+	name string, apply ...func(*SelectQuery) *SelectQuery,
+) *SelectQuery {
+	return q.whereHas("NOT EXISTS", name, apply)
+}
+
+func (q *SelectQuery) whereHas(
+	op, name string, apply []func(*SelectQuery) *SelectQuery,
+) *SelectQuery {
+	if q.table == nil {
+		q.setErr(errNilModel)
+		return q
+	}
+
+	subq, err := q.relationPathSubquery(q.table, strings.Split(name, "."), apply)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+
+	q.addWhere(schema.SafeQueryWithSep(op+" (?)", []interface{}{subq}, " AND "))
+	return q
+}
+
+// WhereFrom copies the conditions of the update or delete query, including WherePK,
+// soft delete, and optimistic locking conditions, to select the rows modified by it.
+// The CTEs, tables, and joins of the query are copied too, so the select returns
+// a row for each match when the conditions match several rows of the joined tables.
+// The select query must have the model of the update or delete query.
+func (q *SelectQuery) WhereFrom(query Query) *SelectQuery {
+	var src *whereBaseQuery
+	switch query := query.(type) {
+	case *UpdateQuery:
+		src = &query.whereBaseQuery
+		q.joins = append(q.joins, query.joins...)
+	case *DeleteQuery:
+		src = &query.whereBaseQuery
+	default:
+		q.setErr(fmt.Errorf("bun: WhereFrom does not support %T", query))
+		return q
+	}
+
+	q.with = append(q.with, src.with...)
+	if !src.modelTableName.IsZero() {
+		q.modelTableName = src.modelTableName
+	}
+	q.tables = append(q.tables, src.tables...)
+
+	q.where = append(q.where, src.where...)
+	if src.whereFields != nil {
+		q.whereFields = src.whereFields
+	}
+	q.withoutScopes = append(q.withoutScopes, src.withoutScopes...)
+	for _, flag := range []internal.Flag{
+		forceDeleteFlag, deletedFlag, allWithDeletedFlag, versionFlag,
+	} {
+		if src.flags.Has(flag) {
+			q.flags = q.flags.Set(flag)
+		}
+	}
+	return q
+}
+
 func (q *SelectQuery) WhereDeleted() *SelectQuery {
 	q.whereDeleted()
 	return q
@@ -171,6 +260,13 @@ func (q *SelectQuery) WhereAllWithDeleted() *SelectQuery {
 	return q
 }
 
+// WithoutScope disables the named default scopes of the model.
+// See DefaultScoper.
+func (q *SelectQuery) WithoutScope(names ...string) *SelectQuery {
+	q.withoutScope(names)
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *SelectQuery) UseIndex(indexes ...string) *SelectQuery {
@@ -276,6 +372,17 @@ func (q *SelectQuery) Having(having string, args ...interface{}) *SelectQuery {
 	return q
 }
 
+// Window adds a named window to the `WINDOW` clause. Use Over to reference the window.
+func (q *SelectQuery) Window(name string, fn func(*WindowDef)) *SelectQuery {
+	def := new(WindowDef)
+	fn(def)
+	q.windows = append(q.windows, namedWindow{
+		name: name,
+		def:  def,
+	})
+	return q
+}
+
 func (q *SelectQuery) Order(orders ...string) *SelectQuery {
 	q.addOrder(orders...)
 	return q
@@ -301,6 +408,28 @@ func (q *SelectQuery) For(s string, args ...interface{}) *SelectQuery {
 	return q
 }
 
+// UsePrimary executes the query on the primary database instead of a replica.
+// See WithReplicas.
+func (q *SelectQuery) UsePrimary() *SelectQuery {
+	q.flags = q.flags.Set(usePrimaryFlag)
+	return q
+}
+
+// readOnly reports whether the query can be executed on a replica.
+func (q *SelectQuery) readOnly() bool {
+	if q.flags.Has(usePrimaryFlag) || !q.selFor.IsZero() {
+		return false
+	}
+	for _, with := range q.with {
+		switch with.query.(type) {
+		case *SelectQuery, *ValuesQuery:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 //------------------------------------------------------------------------------
 
 func (q *SelectQuery) Union(other *SelectQuery) *SelectQuery {
@@ -344,6 +473,37 @@ func (q *SelectQuery) Join(join string, args ...interface{}) *SelectQuery {
 	return q
 }
 
+// JoinSubquery joins the subquery using the join kind, for example, "LEFT JOIN".
+// Columns selected by the subquery are added to the query as `alias__column`
+// so they can be scanned into a struct field tagged with `bun:"alias,scanonly"`.
+// They are not added when the columns are selected with Column, ColumnExpr,
+// or ExcludeColumn.
+func (q *SelectQuery) JoinSubquery(kind, alias string, subq *SelectQuery) *SelectQuery {
+	q.joins = append(q.joins, joinQuery{
+		join:     schema.SafeQuery(kind, nil),
+		subquery: subq,
+		alias:    alias,
+	})
+	return q
+}
+
+// JoinLateral joins the correlated subquery using `LEFT JOIN LATERAL` or `OUTER APPLY` on MSSQL.
+// The subquery columns are scanned the same way as with JoinSubquery.
+// SQLite, MariaDB, and MySQL before 8.0.14 don't support lateral joins.
+func (q *SelectQuery) JoinLateral(alias string, subq *SelectQuery) *SelectQuery {
+	if !q.db.HasFeature(feature.LateralJoin) {
+		q.setErr(fmt.Errorf("bun: %s does not support lateral joins", q.db.Dialect().Name()))
+		return q
+	}
+	q.joins = append(q.joins, joinQuery{
+		join:     schema.SafeQuery("LEFT JOIN LATERAL", nil),
+		subquery: subq,
+		alias:    alias,
+		lateral:  true,
+	})
+	return q
+}
+
 func (q *SelectQuery) JoinOn(cond string, args ...interface{}) *SelectQuery {
 	return q.joinOn(cond, args, " AND ")
 }
@@ -365,6 +525,10 @@ func (q *SelectQuery) joinOn(cond string, args []interface{}, sep string) *Selec
 //------------------------------------------------------------------------------
 
 // Relation adds a relation to the query.
+//
+// For has-many and m2m relations, Limit and Offset set by the apply function
+// are applied per parent row using the order of the relation query, for example,
+// to select the latest 3 comments of each post. This requires window functions.
 func (q *SelectQuery) Relation(name string, apply ...func(*SelectQuery) *SelectQuery) *SelectQuery {
 	if len(apply) > 1 {
 		panic("only one apply function is supported")
@@ -411,6 +575,45 @@ func (q *SelectQuery) Relation(name string, apply ...func(*SelectQuery) *SelectQ
 	return q
 }
 
+// RelationCount selects the number of related rows without loading them.
+// The count is selected as a column with the name into, so the model needs
+// a matching field, for example, `bun:"comment_count,scanonly"`.
+func (q *SelectQuery) RelationCount(
+	name, into string, apply ...func(*SelectQuery) *SelectQuery,
+) *SelectQuery {
+	return q.RelationAggregate(name, "count(*)", into, apply...)
+}
+
+// RelationAggregate selects an aggregate expression, for example, "sum(item.price)",
+// over the related rows as a column with the name into.
+func (q *SelectQuery) RelationAggregate(
+	name, expr, into string, apply ...func(*SelectQuery) *SelectQuery,
+) *SelectQuery {
+	if q.table == nil {
+		q.setErr(errNilModel)
+		return q
+	}
+
+	rel, ok := q.table.Relations[name]
+	if !ok {
+		q.setErr(fmt.Errorf("%s does not have relation=%q", q.table, name))
+		return q
+	}
+
+	subq, err := q.relationSubquery(q.table.SQLAlias, rel)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+
+	subq = subq.ColumnExpr(expr).Apply(apply...)
+
+	q.relationAggs = append(q.relationAggs, schema.SafeQuery("(?) AS ?", []interface{}{
+		subq, Ident(into),
+	}))
+	return q
+}
+
 func (q *SelectQuery) forEachInlineRelJoin(fn func(*relationJoin) error) error {
 	if q.tableModel == nil {
 		return nil
@@ -434,6 +637,15 @@ func (q *SelectQuery) _forEachInlineRelJoin(fn func(*relationJoin) error, joins
 	return nil
 }
 
+// relationQuery returns a query to load relations that inherits the connection settings.
+func (q *SelectQuery) relationQuery() *SelectQuery {
+	rq := q.db.NewSelect().Conn(q.conn).WithoutScope(q.withoutScopes...)
+	if q.flags.Has(usePrimaryFlag) {
+		rq = rq.UsePrimary()
+	}
+	return rq
+}
+
 func (q *SelectQuery) selectJoins(ctx context.Context, joins []relationJoin) error {
 	for i := range joins {
 		j := &joins[i]
@@ -444,9 +656,9 @@ func (q *SelectQuery) selectJoins(ctx context.Context, joins []relationJoin) err
 		case schema.HasOneRelation, schema.BelongsToRelation:
 			err = q.selectJoins(ctx, j.JoinModel.getJoins())
 		case schema.HasManyRelation:
-			err = j.selectMany(ctx, q.db.NewSelect().Conn(q.conn))
+			err = j.selectMany(ctx, q.relationQuery())
 		case schema.ManyToManyRelation:
-			err = j.selectM2M(ctx, q.db.NewSelect().Conn(q.conn))
+			err = j.selectM2M(ctx, q.relationQuery())
 		default:
 			panic("not reached")
 		}
@@ -476,12 +688,26 @@ func (q *SelectQuery) appendQuery(
 	}
 
 	fmter = formatterWithModel(fmter, q)
+	b = prependComment(b, q.comment)
 
 	cteCount := count && (len(q.group) > 0 || q.distinctOn != nil)
 	if cteCount {
 		b = append(b, "WITH _count_wrapper AS ("...)
 	}
 
+	partitioned := !count && q.isPartitioned()
+	if partitioned {
+		if !fmter.HasFeature(feature.WindowFunctions) {
+			return nil, fmt.Errorf(
+				"bun: %s does not support window functions required to limit %s per parent",
+				fmter.Dialect().Name(), q.table)
+		}
+		if !q.selFor.IsZero() {
+			return nil, fmt.Errorf("bun: can't use FOR when %s is limited per parent", q.table)
+		}
+		b = append(b, "SELECT * FROM ("...)
+	}
+
 	if len(q.union) > 0 {
 		b = append(b, '(')
 	}
@@ -513,7 +739,7 @@ func (q *SelectQuery) appendQuery(
 		b = append(b, "count(*)"...)
 	} else {
 		// MSSQL: allows Limit() without Order() as per https://stackoverflow.com/a/36156953
-		if q.limit > 0 && len(q.order) == 0 && fmter.Dialect().Name() == dialect.MSSQL {
+		if !partitioned && q.limit > 0 && len(q.order) == 0 && fmter.Dialect().Name() == dialect.MSSQL {
 			b = append(b, "0 AS _temp_sort, "...)
 		}
 
@@ -521,6 +747,13 @@ func (q *SelectQuery) appendQuery(
 		if err != nil {
 			return nil, err
 		}
+
+		if partitioned {
+			b, err = q.appendRowNumber(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	if q.hasTables() {
@@ -550,6 +783,10 @@ func (q *SelectQuery) appendQuery(
 		}
 	}
 
+	if err := q.applyScopes(); err != nil {
+		return nil, err
+	}
+
 	b, err = q.appendWhere(fmter, b, true)
 	if err != nil {
 		return nil, err
@@ -583,7 +820,22 @@ func (q *SelectQuery) appendQuery(
 		}
 	}
 
-	if !count {
+	if len(q.windows) > 0 {
+		b = append(b, " WINDOW "...)
+		for i, w := range q.windows {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b, err = w.AppendQuery(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if partitioned {
+		b = q.appendPartitionLimit(fmter, b)
+	} else if !count {
 		b, err = q.appendOrder(fmter, b)
 		if err != nil {
 			return nil, err
@@ -624,6 +876,54 @@ func (q *SelectQuery) appendQuery(
 	return b, nil
 }
 
+func (q *SelectQuery) isPartitioned() bool {
+	return q.partition != "" && (q.limit > 0 || q.offset > 0)
+}
+
+// rowNumberColumn is the alias of the row number selected by partitioned queries.
+const rowNumberColumn = "_row_number"
+
+// appendRowNumber numbers rows within each partition using the query order.
+func (q *SelectQuery) appendRowNumber(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	def := &WindowDef{
+		partition: []schema.QueryWithArgs{schema.SafeQuery(string(q.partition), nil)},
+		order:     q.order,
+	}
+	if len(def.order) == 0 {
+		def.order = def.partition
+	}
+
+	b = append(b, ", ROW_NUMBER() OVER "...)
+	b, err = def.AppendQuery(fmter, b)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, " AS "+rowNumberColumn...)
+	return b, nil
+}
+
+// appendPartitionLimit closes the subquery created for a partitioned query
+// and applies limit and offset to every partition.
+func (q *SelectQuery) appendPartitionLimit(fmter schema.Formatter, b []byte) []byte {
+	b = append(b, ')')
+	if fmter.Dialect().Name() == dialect.Oracle {
+		b = append(b, ' ')
+	} else {
+		b = append(b, " AS "...)
+	}
+	b = append(b, q.table.SQLAlias...)
+
+	b = append(b, " WHERE "+rowNumberColumn+" > "...)
+	b = strconv.AppendInt(b, int64(q.offset), 10)
+	if q.limit > 0 {
+		b = append(b, " AND "+rowNumberColumn+" <= "...)
+		b = strconv.AppendInt(b, int64(q.offset)+int64(q.limit), 10)
+	}
+
+	b = append(b, " ORDER BY "+rowNumberColumn...)
+	return b
+}
+
 func (q *SelectQuery) appendColumns(fmter schema.Formatter, b []byte) (_ []byte, err error) {
 	start := len(b)
 
@@ -676,6 +976,33 @@ func (q *SelectQuery) appendColumns(fmter schema.Formatter, b []byte) (_ []byte,
 		return nil, err
 	}
 
+	for i := range q.joins {
+		join := &q.joins[i]
+		// Explicitly selected columns replace the subquery columns.
+		if join.subquery == nil || q.columns != nil {
+			continue
+		}
+
+		for _, column := range join.subquery.selectedColumns() {
+			if len(b) != start {
+				b = append(b, ", "...)
+				start = len(b)
+			}
+			b = join.appendColumn(fmter, b, column)
+		}
+	}
+
+	for _, agg := range q.relationAggs {
+		if len(b) != start {
+			b = append(b, ", "...)
+			start = len(b)
+		}
+		b, err = agg.AppendQuery(fmter, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	b = bytes.TrimSuffix(b, []byte(", "))
 
 	return b, nil
@@ -725,6 +1052,74 @@ func (q *SelectQuery) appendInlineRelColumns(
 	return b, nil
 }
 
+// selectedColumns returns the names of the columns selected by the query.
+// Column expressions are only included when they have an alias, e.g. `count(*) AS num`.
+func (q *SelectQuery) selectedColumns() []string {
+	if q.columns == nil {
+		if q.table == nil {
+			return nil
+		}
+		columns := make([]string, len(q.table.Fields))
+		for i, f := range q.table.Fields {
+			columns[i] = f.Name
+		}
+		return columns
+	}
+
+	columns := make([]string, 0, len(q.columns))
+	for _, col := range q.columns {
+		if col.Args != nil {
+			if alias := exprAlias(col.Query); alias != "" {
+				columns = append(columns, alias)
+			}
+			continue
+		}
+
+		if q.table != nil {
+			if field, ok := q.table.FieldMap[col.Query]; ok {
+				columns = append(columns, field.Name)
+				continue
+			}
+		}
+
+		column := col.Query
+		if i := strings.LastIndexByte(column, '.'); i >= 0 {
+			column = column[i+1:]
+		}
+		if column != "*" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// exprAlias returns the alias of a column expression like `count(*) AS num`.
+func exprAlias(expr string) string {
+	i := strings.LastIndex(strings.ToUpper(expr), " AS ")
+	if i == -1 {
+		return ""
+	}
+
+	alias := strings.TrimSpace(expr[i+4:])
+	if len(alias) >= 2 {
+		switch alias[0] {
+		case '"', '`':
+			if alias[len(alias)-1] == alias[0] {
+				alias = alias[1 : len(alias)-1]
+			}
+		case '[':
+			if alias[len(alias)-1] == ']' {
+				alias = alias[1 : len(alias)-1]
+			}
+		}
+	}
+
+	if strings.ContainsAny(alias, " ()?,.") {
+		return ""
+	}
+	return alias
+}
+
 func (q *SelectQuery) appendTables(fmter schema.Formatter, b []byte) (_ []byte, err error) {
 	b = append(b, " FROM "...)
 	return q.appendTablesWithAlias(fmter, b)
@@ -741,19 +1136,32 @@ func (q *SelectQuery) Rows(ctx context.Context) (*sql.Rows, error) {
 		return nil, err
 	}
 
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
 
-	query := internal.String(queryBytes)
+	query := q.db.commentQuery(ctx, internal.String(queryBytes))
 
-	ctx, event := q.db.beforeQuery(ctx, q, query, nil, query, q.model)
-	rows, err := q.conn.QueryContext(ctx, query)
+	conn := q.resolveConn(ctx, q)
+	ctx, event := q.db.beforeQuery(ctx, q, query, nil, query, q.model, conn)
+	rows, err := conn.QueryContext(ctx, query)
 	q.db.afterQuery(ctx, event, nil, err)
 	return rows, err
 }
 
+// Explain returns the plan of the query generated with the dialect EXPLAIN statement.
+// The statement is not executed unless opts.Analyze is set.
+func (q *SelectQuery) Explain(ctx context.Context, opts *ExplainOptions) (*QueryPlan, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	if err := q.beforeAppendModel(ctx, q); err != nil {
+		return nil, err
+	}
+	return q.explain(ctx, q, opts)
+}
+
 func (q *SelectQuery) Exec(ctx context.Context, dest ...interface{}) (res sql.Result, err error) {
 	if q.err != nil {
 		return nil, q.err
@@ -762,7 +1170,7 @@ func (q *SelectQuery) Exec(ctx context.Context, dest ...interface{}) (res sql.Re
 		return nil, err
 	}
 
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -814,13 +1222,26 @@ func (q *SelectQuery) scanResult(ctx context.Context, dest ...interface{}) (sql.
 		return nil, err
 	}
 
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
 
 	query := internal.String(queryBytes)
 
+	var cacheKey string
+	if q.useCache() {
+		cacheKey = q.cacheKey(query)
+		if numRow, ok := q.cacheGet(ctx, cacheKey, model, dest); ok {
+			if q.table != nil {
+				if err := q.afterSelectHook(ctx); err != nil {
+					return nil, err
+				}
+			}
+			return driver.RowsAffected(numRow), nil
+		}
+	}
+
 	res, err := q.scan(ctx, q, query, model, true)
 	if err != nil {
 		return nil, err
@@ -834,6 +1255,13 @@ func (q *SelectQuery) scanResult(ctx context.Context, dest ...interface{}) (sql.
 		}
 	}
 
+	if cacheKey != "" {
+		n, _ := res.RowsAffected()
+		if err := q.cacheSet(ctx, cacheKey, model, dest, int(n)); err != nil {
+			return nil, err
+		}
+	}
+
 	if q.table != nil {
 		if err := q.afterSelectHook(ctx); err != nil {
 			return nil, err
@@ -868,16 +1296,17 @@ func (q *SelectQuery) Count(ctx context.Context) (int, error) {
 
 	qq := countQuery{q}
 
-	queryBytes, err := qq.AppendQuery(q.db.fmter, nil)
+	queryBytes, err := qq.AppendQuery(q.db.formatter(ctx), nil)
 	if err != nil {
 		return 0, err
 	}
 
-	query := internal.String(queryBytes)
-	ctx, event := q.db.beforeQuery(ctx, qq, query, nil, query, q.model)
+	query := q.db.commentQuery(ctx, internal.String(queryBytes))
+	conn := q.resolveConn(ctx, qq)
+	ctx, event := q.db.beforeQuery(ctx, qq, query, nil, query, q.model, conn)
 
 	var num int
-	err = q.conn.QueryRowContext(ctx, query).Scan(&num)
+	err = conn.QueryRowContext(ctx, query).Scan(&num)
 
 	q.db.afterQuery(ctx, event, nil, err)
 
@@ -970,16 +1399,17 @@ func (q *SelectQuery) Exists(ctx context.Context) (bool, error) {
 func (q *SelectQuery) selectExists(ctx context.Context) (bool, error) {
 	qq := selectExistsQuery{q}
 
-	queryBytes, err := qq.AppendQuery(q.db.fmter, nil)
+	queryBytes, err := qq.AppendQuery(q.db.formatter(ctx), nil)
 	if err != nil {
 		return false, err
 	}
 
-	query := internal.String(queryBytes)
-	ctx, event := q.db.beforeQuery(ctx, qq, query, nil, query, q.model)
+	query := q.db.commentQuery(ctx, internal.String(queryBytes))
+	conn := q.resolveConn(ctx, qq)
+	ctx, event := q.db.beforeQuery(ctx, qq, query, nil, query, q.model, conn)
 
 	var exists bool
-	err = q.conn.QueryRowContext(ctx, query).Scan(&exists)
+	err = conn.QueryRowContext(ctx, query).Scan(&exists)
 
 	q.db.afterQuery(ctx, event, nil, err)
 
@@ -989,7 +1419,7 @@ func (q *SelectQuery) selectExists(ctx context.Context) (bool, error) {
 func (q *SelectQuery) whereExists(ctx context.Context) (bool, error) {
 	qq := whereExistsQuery{q}
 
-	queryBytes, err := qq.AppendQuery(q.db.fmter, nil)
+	queryBytes, err := qq.AppendQuery(q.db.formatter(ctx), nil)
 	if err != nil {
 		return false, err
 	}
@@ -1019,6 +1449,13 @@ func (q *SelectQuery) String() string {
 
 //------------------------------------------------------------------------------
 
+func (q *SelectQuery) applyScopes() error {
+	return q.collectScopes(func() (QueryBuilder, *whereBaseQuery) {
+		scoped := &SelectQuery{whereBaseQuery: q.scopeQuery()}
+		return scoped.QueryBuilder(), &scoped.whereBaseQuery
+	})
+}
+
 func (q *SelectQuery) QueryBuilder() QueryBuilder {
 	return &selectQueryBuilder{q}
 }
@@ -1074,16 +1511,32 @@ func (q *selectQueryBuilder) Unwrap() interface{} {
 type joinQuery struct {
 	join schema.QueryWithArgs
 	on   []schema.QueryWithSep
+
+	// subquery, alias, and lateral are set by JoinSubquery and JoinLateral.
+	subquery *SelectQuery
+	alias    string
+	lateral  bool
 }
 
 func (j *joinQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
 	b = append(b, ' ')
 
-	b, err = j.join.AppendQuery(fmter, b)
+	if j.subquery != nil {
+		b, err = j.appendSubquery(fmter, b)
+	} else {
+		b, err = j.join.AppendQuery(fmter, b)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if j.lateral && len(j.on) == 0 {
+		if fmter.Dialect().Name() != dialect.MSSQL {
+			b = append(b, " ON TRUE"...)
+		}
+		return b, nil
+	}
+
 	if len(j.on) > 0 {
 		b = append(b, " ON "...)
 		for i, on := range j.on {
@@ -1103,6 +1556,44 @@ func (j *joinQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err
 	return b, nil
 }
 
+func (j *joinQuery) appendSubquery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	if j.lateral && fmter.Dialect().Name() == dialect.MSSQL {
+		if len(j.on) > 0 {
+			return nil, errors.New("bun: OUTER APPLY does not support ON conditions")
+		}
+		b = append(b, "OUTER APPLY"...)
+	} else {
+		b, err = j.join.AppendQuery(fmter, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b = append(b, " ("...)
+	b, err = j.subquery.AppendQuery(fmter, b)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, ')')
+	if fmter.Dialect().Name() == dialect.Oracle {
+		b = append(b, ' ')
+	} else {
+		b = append(b, " AS "...)
+	}
+	b = fmter.AppendIdent(b, j.alias)
+
+	return b, nil
+}
+
+func (j *joinQuery) appendColumn(fmter schema.Formatter, b []byte, column string) []byte {
+	b = fmter.AppendIdent(b, j.alias)
+	b = append(b, '.')
+	b = fmter.AppendName(b, column)
+	b = append(b, " AS "...)
+	b = fmter.AppendName(b, j.alias+"__"+column)
+	return b
+}
+
 //------------------------------------------------------------------------------
 
 type countQuery struct {
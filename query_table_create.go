@@ -303,7 +303,7 @@ func (q *CreateTableQuery) appendFKConstraintsRel(fmter schema.Formatter, b []by
 				Query: "(?) REFERENCES ? (?) ? ?",
 				Args: []interface{}{
 					Safe(appendColumns(nil, "", rel.BasePKs)),
-					rel.JoinTable.SQLName,
+					tableName{rel.JoinTable},
 					Safe(appendColumns(nil, "", rel.JoinPKs)),
 					Safe(rel.OnUpdate),
 					Safe(rel.OnDelete),
@@ -347,7 +347,7 @@ func (q *CreateTableQuery) Exec(ctx context.Context, dest ...interface{}) (sql.R
 		return nil, err
 	}
 
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/uptrace/bun/dialect"
 
@@ -21,8 +23,10 @@ type UpdateQuery struct {
 	setQuery
 	idxHintsQuery
 
-	joins    []joinQuery
-	omitZero bool
+	joins     []joinQuery
+	relations []string
+	omitZero  bool
+	bulk      bool
 }
 
 var _ Query = (*UpdateQuery)(nil)
@@ -31,8 +35,9 @@ func NewUpdateQuery(db *DB) *UpdateQuery {
 	q := &UpdateQuery{
 		whereBaseQuery: whereBaseQuery{
 			baseQuery: baseQuery{
-				db:   db,
-				conn: db.DB,
+				db:    db,
+				conn:  db.DB,
+				flags: versionFlag,
 			},
 		},
 	}
@@ -54,6 +59,13 @@ func (q *UpdateQuery) Err(err error) *UpdateQuery {
 	return q
 }
 
+// Comment adds the comment to the start of the query, for example,
+// to find the code that executed the query in the database logs.
+func (q *UpdateQuery) Comment(comment string) *UpdateQuery {
+	q.comment = comment
+	return q
+}
+
 // Apply calls each function in fns, passing the UpdateQuery as an argument.
 func (q *UpdateQuery) Apply(fns ...func(*UpdateQuery) *UpdateQuery) *UpdateQuery {
 	for _, fn := range fns {
@@ -203,6 +215,13 @@ func (q *UpdateQuery) WhereAllWithDeleted() *UpdateQuery {
 	return q
 }
 
+// WithoutScope disables the named default scopes of the model.
+// See DefaultScoper.
+func (q *UpdateQuery) WithoutScope(names ...string) *UpdateQuery {
+	q.withoutScope(names)
+	return q
+}
+
 // ------------------------------------------------------------------------------
 func (q *UpdateQuery) Order(orders ...string) *UpdateQuery {
 	if !q.hasFeature(feature.UpdateOrderLimit) {
@@ -253,6 +272,7 @@ func (q *UpdateQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, e
 	}
 
 	fmter = formatterWithModel(fmter, q)
+	b = prependComment(b, q.comment)
 
 	b, err = q.appendWith(fmter, b)
 	if err != nil {
@@ -304,6 +324,10 @@ func (q *UpdateQuery) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, e
 		}
 	}
 
+	if err := q.applyScopes(); err != nil {
+		return nil, err
+	}
+
 	b, err = q.mustAppendWhere(fmter, b, q.hasTableAlias(fmter))
 	if err != nil {
 		return nil, err
@@ -334,7 +358,19 @@ func (q *UpdateQuery) mustAppendSet(fmter schema.Formatter, b []byte) (_ []byte,
 	b = append(b, " SET "...)
 
 	if len(q.set) > 0 {
-		return q.appendSet(fmter, b)
+		b, err = q.appendSet(fmter, b)
+		if err != nil {
+			return nil, err
+		}
+		if field := q.whereBaseQuery.versionField(); field != nil {
+			b = append(b, ", "...)
+			b = q.appendVersionSet(fmter, b, field, "")
+		}
+		if q.table != nil && q.table.UpdatedAtField != nil && !q.setHasColumn(fmter, q.table.UpdatedAtField) {
+			b = append(b, ", "...)
+			b = q.appendUpdatedAtSet(fmter, b)
+		}
+		return b, nil
 	}
 
 	if m, ok := q.model.(*mapModel); ok {
@@ -376,7 +412,16 @@ func (q *UpdateQuery) appendSetStruct(
 		return nil, err
 	}
 
+	// The version field is always incremented unless it has a custom value.
+	versionField := q.whereBaseQuery.versionField()
+	if versionField != nil {
+		if _, ok := q.modelValues[versionField.Name]; ok {
+			versionField = nil
+		}
+	}
+
 	isTemplate := fmter.IsNop()
+	start := len(b)
 	pos := len(b)
 	for _, f := range fields {
 		if f.SkipUpdate() {
@@ -385,6 +430,10 @@ func (q *UpdateQuery) appendSetStruct(
 
 		app, hasValue := q.modelValues[f.Name]
 
+		if f == versionField {
+			continue
+		}
+
 		if !hasValue && q.omitZero && f.HasZeroValue(model.strct) {
 			continue
 		}
@@ -402,18 +451,37 @@ func (q *UpdateQuery) appendSetStruct(
 			continue
 		}
 
-		if hasValue {
+		switch {
+		case hasValue:
 			b, err = app.AppendQuery(fmter, b)
 			if err != nil {
 				return nil, err
 			}
-		} else {
+		case f == q.table.UpdatedAtField:
+			b = q.appendUpdatedAtValue(fmter, b, model.strct)
+		default:
 			b = f.AppendValue(fmter, b, model.strct)
 		}
 	}
 
+	if versionField != nil {
+		if len(b) != pos {
+			b = append(b, ", "...)
+			pos = len(b)
+		}
+		b = q.appendVersionSet(fmter, b, versionField, "")
+	}
+
+	// The updated_at field is always updated, even when it is not in the column list.
+	if f := q.table.UpdatedAtField; f != nil && !f.SkipUpdate() && !containsField(fields, f) {
+		if len(b) != pos {
+			b = append(b, ", "...)
+		}
+		b = q.appendUpdatedAtSet(fmter, b)
+	}
+
 	for i, v := range q.extraValues {
-		if i > 0 || len(fields) > 0 {
+		if i > 0 || len(b) != start {
 			b = append(b, ", "...)
 		}
 
@@ -444,6 +512,13 @@ func (q *UpdateQuery) appendOtherTables(fmter schema.Formatter, b []byte) (_ []b
 	return b, nil
 }
 
+// WithRelations saves the named relations together with the model using the same IConn.
+// See InsertQuery.WithRelations for details.
+func (q *UpdateQuery) WithRelations(names ...string) *UpdateQuery {
+	q.relations = append(q.relations, names...)
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *UpdateQuery) Bulk() *UpdateQuery {
@@ -461,6 +536,7 @@ func (q *UpdateQuery) Bulk() *UpdateQuery {
 
 	values := q.db.NewValues(model)
 	values.customValueQuery = q.customValueQuery
+	q.bulk = true
 
 	return q.With("_data", values).
 		Model(model).
@@ -491,6 +567,10 @@ func (q *UpdateQuery) updateSliceSet(
 			b = append(b, model.table.SQLAlias...)
 			b = append(b, '.')
 		}
+		if field == model.table.VersionField {
+			b = q.appendVersionSet(fmter, b, field, "_data")
+			continue
+		}
 		b = append(b, field.SQLName...)
 		b = append(b, " = _data."...)
 		b = append(b, field.SQLName...)
@@ -498,6 +578,127 @@ func (q *UpdateQuery) updateSliceSet(
 	return internal.String(b), nil
 }
 
+// setHasColumn reports whether the SET clause assigns the column.
+// The SET queries are formatted first to resolve the placeholders,
+// for example, Set("? = ?", bun.Ident("updated_at"), tm).
+func (q *UpdateQuery) setHasColumn(fmter schema.Formatter, field *schema.Field) bool {
+	if fmter.IsNop() {
+		fmter = q.db.fmter
+	}
+	for _, set := range q.set {
+		b, err := set.AppendQuery(fmter, nil)
+		if err != nil {
+			continue
+		}
+		for _, column := range setColumns(internal.String(b)) {
+			if column == field.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setColumns returns the unquoted column names on the left side
+// of the assignments in the SET query, for example, "a = ?, t.b = ?".
+func setColumns(query string) []string {
+	var columns []string
+	var depth int
+	var quote byte
+	start := 0
+	for i := 0; i <= len(query); i++ {
+		if i < len(query) {
+			c := query[i]
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+				continue
+			case c == '(':
+				depth++
+				continue
+			case c == ')':
+				depth--
+				continue
+			case c != ',' || depth > 0:
+				continue
+			}
+		}
+
+		assignment := query[start:i]
+		start = i + 1
+		if ind := strings.IndexByte(assignment, '='); ind != -1 {
+			columns = append(columns, unquoteColumn(assignment[:ind]))
+		}
+	}
+	return columns
+}
+
+func unquoteColumn(s string) string {
+	s = strings.TrimSpace(s)
+	if ind := strings.LastIndexByte(s, '.'); ind != -1 {
+		s = s[ind+1:]
+	}
+	return strings.Trim(s, "\"`[]")
+}
+
+func (q *UpdateQuery) appendUpdatedAtSet(fmter schema.Formatter, b []byte) []byte {
+	field := q.table.UpdatedAtField
+	b = append(b, field.SQLName...)
+	b = append(b, " = "...)
+	if fmter.IsNop() {
+		return append(b, '?')
+	}
+	var strct reflect.Value
+	if model, ok := q.tableModel.(*structTableModel); ok {
+		strct = model.strct
+	}
+	return q.appendUpdatedAtValue(fmter, b, strct)
+}
+
+// appendUpdatedAtValue appends the value set on the updated_at field before the query
+// was executed. When the query is only formatted, it appends the current time
+// without changing the model.
+func (q *UpdateQuery) appendUpdatedAtValue(
+	fmter schema.Formatter, b []byte, strct reflect.Value,
+) []byte {
+	field := q.table.UpdatedAtField
+	if !q.now.IsZero() && strct.IsValid() {
+		return field.AppendValue(fmter, b, strct)
+	}
+
+	fv := reflect.New(field.StructField.Type).Elem()
+	if err := q.table.UpdateUpdatedAtField(fv, q.timestamp()); err != nil {
+		return schema.Append(fmter, b, q.timestamp())
+	}
+	return field.Append(fmter, b, fv)
+}
+
+// appendVersionSet appends `version = version + 1` taking the old version
+// from the source table if it is set.
+func (q *UpdateQuery) appendVersionSet(
+	fmter schema.Formatter, b []byte, field *schema.Field, source schema.Safe,
+) []byte {
+	if source == "" && fmter.HasFeature(feature.UpdateMultiTable) {
+		source = q.table.SQLAlias
+		b = append(b, source...)
+		b = append(b, '.')
+	}
+	b = append(b, field.SQLName...)
+	b = append(b, " = "...)
+	if source != "" {
+		b = append(b, source...)
+		b = append(b, '.')
+	}
+	b = append(b, field.SQLName...)
+	b = append(b, " + 1"...)
+	return b
+}
+
 func (q *UpdateQuery) updateSliceWhere(fmter schema.Formatter, model *sliceTableModel) string {
 	var b []byte
 	for i, pk := range model.table.PKs {
@@ -514,6 +715,18 @@ func (q *UpdateQuery) updateSliceWhere(fmter schema.Formatter, model *sliceTable
 		b = append(b, " = _data."...)
 		b = append(b, pk.SQLName...)
 	}
+	if field := model.table.VersionField; field != nil {
+		b = append(b, " AND "...)
+		if q.hasTableAlias(fmter) {
+			b = append(b, model.table.SQLAlias...)
+		} else {
+			b = append(b, model.table.SQLName...)
+		}
+		b = append(b, '.')
+		b = append(b, field.SQLName...)
+		b = append(b, " = _data."...)
+		b = append(b, field.SQLName...)
+	}
 	return internal.String(b)
 }
 
@@ -528,6 +741,16 @@ func (q *UpdateQuery) Exec(ctx context.Context, dest ...interface{}) (sql.Result
 	return q.scanOrExec(ctx, dest, len(dest) > 0)
 }
 
+// Explain returns the plan of the query generated with the dialect EXPLAIN statement.
+// The statement is not executed unless opts.Analyze is set. Explain doesn't run
+// the model hooks and doesn't change the timestamp and soft delete fields of the model.
+func (q *UpdateQuery) Explain(ctx context.Context, opts *ExplainOptions) (*QueryPlan, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.explain(ctx, q, opts)
+}
+
 func (q *UpdateQuery) scanOrExec(
 	ctx context.Context, dest []interface{}, hasDest bool,
 ) (sql.Result, error) {
@@ -535,19 +758,46 @@ func (q *UpdateQuery) scanOrExec(
 		return nil, q.err
 	}
 
+	if len(q.relations) == 0 || isTx(q.conn) {
+		return q._scanOrExec(ctx, dest, hasDest)
+	}
+
+	var res sql.Result
+	err := q.runInRelationsTx(ctx, func(ctx context.Context) (err error) {
+		res, err = q._scanOrExec(ctx, dest, hasDest)
+		return err
+	})
+	return res, err
+}
+
+func (q *UpdateQuery) _scanOrExec(
+	ctx context.Context, dest []interface{}, hasDest bool,
+) (sql.Result, error) {
+
 	if q.table != nil {
 		if err := q.beforeUpdateHook(ctx); err != nil {
 			return nil, err
 		}
 	}
 
+	if len(q.relations) > 0 {
+		if err := q.saveRelationsBefore(ctx, q.relations); err != nil {
+			return nil, err
+		}
+	}
+
 	// Run append model hooks before generating the query.
 	if err := q.beforeAppendModel(ctx, q); err != nil {
 		return nil, err
 	}
 
+	q.now = q.db.now()
+	if err := q.updateTimestamps(q.now, false); err != nil {
+		return nil, err
+	}
+
 	// Generate the query before checking hasReturning.
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	queryBytes, err := q.AppendQuery(q.db.formatter(ctx), q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -579,6 +829,18 @@ func (q *UpdateQuery) scanOrExec(
 		}
 	}
 
+	if field := q.versionField(); field != nil {
+		if err := q.checkVersion(res, field, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(q.relations) > 0 {
+		if err := q.saveRelationsAfter(ctx, q.relations); err != nil {
+			return nil, err
+		}
+	}
+
 	if q.table != nil {
 		if err := q.afterUpdateHook(ctx); err != nil {
 			return nil, err
@@ -588,6 +850,13 @@ func (q *UpdateQuery) scanOrExec(
 	return res, nil
 }
 
+func (q *UpdateQuery) versionField() *schema.Field {
+	if q.bulk && q.flags.Has(versionFlag) && q.table != nil {
+		return q.table.VersionField
+	}
+	return q.whereBaseQuery.versionField()
+}
+
 func (q *UpdateQuery) beforeUpdateHook(ctx context.Context) error {
 	if hook, ok := q.table.ZeroIface.(BeforeUpdateHook); ok {
 		if err := hook.BeforeUpdate(ctx, q); err != nil {
@@ -633,6 +902,13 @@ func (q *UpdateQuery) String() string {
 
 //------------------------------------------------------------------------------
 
+func (q *UpdateQuery) applyScopes() error {
+	return q.collectScopes(func() (QueryBuilder, *whereBaseQuery) {
+		scoped := &UpdateQuery{whereBaseQuery: q.scopeQuery()}
+		return scoped.QueryBuilder(), &scoped.whereBaseQuery
+	})
+}
+
 func (q *UpdateQuery) QueryBuilder() QueryBuilder {
 	return &updateQueryBuilder{q}
 }
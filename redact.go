@@ -0,0 +1,93 @@
+package bun
+
+import (
+	"github.com/uptrace/bun/internal"
+	"github.com/uptrace/bun/schema"
+)
+
+// Redaction controls how QueryEvent.RedactedQuery renders the values of a query.
+type Redaction int
+
+const (
+	// RedactNone renders the query as executed.
+	RedactNone Redaction = iota
+	// RedactSensitive masks the values of the model fields with the sensitive
+	// tag option, for example, `bun:",sensitive"`. Values passed as query
+	// arguments, for example, with Where or Set, are masked only when they are
+	// structs with sensitive fields; other arguments are logged as is.
+	// Use RedactValues when the arguments can contain sensitive values.
+	RedactSensitive
+	// RedactValues replaces all values with placeholders.
+	RedactValues
+)
+
+// RedactionHook is implemented by query hooks that use QueryEvent.RedactedQuery.
+// Builder queries are formatted with the redaction before they are executed only
+// when a hook added to the DB requests it, so other hooks don't pay for formatting.
+type RedactionHook interface {
+	QueryHook
+	// Redaction returns the redaction the hook passes to QueryEvent.RedactedQuery.
+	Redaction() Redaction
+}
+
+func (r Redaction) flag() internal.Flag {
+	return internal.Flag(1) << uint(r)
+}
+
+// RedactedQuery returns the query with the values redacted according to the redaction.
+// It is meant to be used by hooks that log queries. The redacted queries are formatted
+// before the query is executed, so calling it doesn't format the query again.
+//
+// Builder queries are redacted only when a RedactionHook with the redaction
+// is added to the DB. Otherwise, only the operation, for example, "SELECT" is returned.
+func (e *QueryEvent) RedactedQuery(redaction Redaction) string {
+	switch redaction {
+	case RedactSensitive:
+		if e.IQuery != nil {
+			if !e.redactions.Has(redaction.flag()) {
+				return e.Operation()
+			}
+			return e.sensitiveQuery
+		}
+		fmter := e.fmter
+		if fmter.Dialect() == nil {
+			if e.DB == nil {
+				return e.QueryTemplate
+			}
+			fmter = e.DB.Formatter()
+		}
+		return fmter.WithSensitiveMask().FormatQuery(e.QueryTemplate, e.QueryArgs...)
+	case RedactValues:
+		if e.IQuery != nil {
+			if !e.redactions.Has(redaction.flag()) {
+				return e.Operation()
+			}
+			return e.valuesQuery
+		}
+		return e.QueryTemplate
+	default:
+		return e.Query
+	}
+}
+
+// formatRedacted formats the redacted variants of the query requested by the hooks.
+// It must be called before the query is executed, so the variants match the executed query.
+func (e *QueryEvent) formatRedacted(redactions internal.Flag) {
+	e.redactions = redactions
+
+	if redactions.Has(RedactSensitive.flag()) {
+		if b, err := e.IQuery.AppendQuery(e.fmter.WithSensitiveMask(), nil); err == nil {
+			e.sensitiveQuery = internal.String(b)
+		} else {
+			e.sensitiveQuery = e.Operation()
+		}
+	}
+
+	if redactions.Has(RedactValues.flag()) {
+		if b, err := e.IQuery.AppendQuery(schema.NewNopFormatter(), nil); err == nil {
+			e.valuesQuery = internal.String(b)
+		} else {
+			e.valuesQuery = e.Operation()
+		}
+	}
+}
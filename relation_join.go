@@ -3,7 +3,6 @@ package bun
 import (
 	"context"
 	"reflect"
-	"time"
 
 	"github.com/uptrace/bun/dialect/feature"
 	"github.com/uptrace/bun/internal"
@@ -94,6 +93,7 @@ func (j *relationJoin) manyQueryCompositeIn(where []byte, q *SelectQuery) *Selec
 
 	j.applyTo(q)
 	q = q.Apply(j.hasManyColumns)
+	q.partition = schema.Safe(appendColumns(nil, j.JoinModel.Table().SQLAlias, j.Relation.JoinPKs))
 
 	return q
 }
@@ -117,6 +117,7 @@ func (j *relationJoin) manyQueryMulti(where []byte, q *SelectQuery) *SelectQuery
 
 	j.applyTo(q)
 	q = q.Apply(j.hasManyColumns)
+	q.partition = schema.Safe(appendColumns(nil, j.JoinModel.Table().SQLAlias, j.Relation.JoinPKs))
 
 	return q
 }
@@ -158,16 +159,14 @@ func (j *relationJoin) hasManyColumns(q *SelectQuery) *SelectQuery {
 }
 
 func (j *relationJoin) selectM2M(ctx context.Context, q *SelectQuery) error {
-	q = j.m2mQuery(q)
+	q = j.m2mQuery(q, q.db.formatter(ctx))
 	if q == nil {
 		return nil
 	}
 	return q.Scan(ctx)
 }
 
-func (j *relationJoin) m2mQuery(q *SelectQuery) *SelectQuery {
-	fmter := q.db.fmter
-
+func (j *relationJoin) m2mQuery(q *SelectQuery, fmter schema.Formatter) *SelectQuery {
 	m2mModel := newM2MModel(j)
 	if m2mModel == nil {
 		return nil
@@ -189,7 +188,7 @@ func (j *relationJoin) m2mQuery(q *SelectQuery) *SelectQuery {
 	//nolint
 	var join []byte
 	join = append(join, "JOIN "...)
-	join = fmter.AppendQuery(join, string(j.Relation.M2MTable.SQLName))
+	join = j.Relation.M2MTable.AppendName(fmter, join)
 	join = append(join, " AS "...)
 	join = append(join, j.Relation.M2MTable.SQLAlias...)
 	join = append(join, " ON ("...)
@@ -216,6 +215,7 @@ func (j *relationJoin) m2mQuery(q *SelectQuery) *SelectQuery {
 
 	j.applyTo(q)
 	q = q.Apply(j.hasManyColumns)
+	q.partition = schema.Safe(appendColumns(nil, j.Relation.M2MTable.SQLAlias, j.Relation.M2MBasePKs))
 
 	return q
 }
@@ -265,27 +265,14 @@ func (j *relationJoin) appendBaseAlias(fmter schema.Formatter, b []byte) []byte
 func (j *relationJoin) appendSoftDelete(
 	fmter schema.Formatter, b []byte, flags internal.Flag,
 ) []byte {
-	b = append(b, '.')
-
-	field := j.JoinModel.Table().SoftDeleteField
-	b = append(b, field.SQLName...)
+	table := j.JoinModel.Table()
 
-	if field.IsPtr || field.NullZero {
-		if flags.Has(deletedFlag) {
-			b = append(b, " IS NOT NULL"...)
-		} else {
-			b = append(b, " IS NULL"...)
-		}
-	} else {
-		if flags.Has(deletedFlag) {
-			b = append(b, " != "...)
-		} else {
-			b = append(b, " = "...)
-		}
-		b = fmter.Dialect().AppendTime(b, time.Time{})
-	}
+	column := j.appendAlias(fmter, nil)
+	column = append(column, '.')
+	column = append(column, table.SoftDeleteField.SQLName...)
 
-	return b
+	return table.SoftDelete.AppendSoftDeleteWhere(
+		fmter, b, schema.Safe(column), flags.Has(deletedFlag))
 }
 
 func appendAlias(b []byte, j *relationJoin) []byte {
@@ -303,7 +290,7 @@ func (j *relationJoin) appendHasOneJoin(
 	isSoftDelete := j.JoinModel.Table().SoftDeleteField != nil && !q.flags.Has(allWithDeletedFlag)
 
 	b = append(b, "LEFT JOIN "...)
-	b = fmter.AppendQuery(b, string(j.JoinModel.Table().SQLNameForSelects))
+	b = j.JoinModel.Table().AppendNameForSelects(fmter, b)
 	b = append(b, " AS "...)
 	b = j.appendAlias(fmter, b)
 
@@ -326,7 +313,6 @@ func (j *relationJoin) appendHasOneJoin(
 
 	if isSoftDelete {
 		b = append(b, " AND "...)
-		b = j.appendAlias(fmter, b)
 		b = j.appendSoftDelete(fmter, b, q.flags)
 	}
 
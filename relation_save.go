@@ -0,0 +1,500 @@
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
+)
+
+type relationSave struct {
+	rel    *schema.Relation
+	nested []string
+}
+
+// relationSaves groups relation paths like "Comments.Author" by the first relation name.
+func relationSaves(table *schema.Table, paths []string) ([]relationSave, error) {
+	var saves []relationSave
+	for _, path := range paths {
+		name, nested, _ := strings.Cut(path, ".")
+
+		rel, ok := table.Relations[name]
+		if !ok {
+			return nil, fmt.Errorf("%s does not have relation=%q", table, name)
+		}
+
+		var save *relationSave
+		for i := range saves {
+			if saves[i].rel == rel {
+				save = &saves[i]
+				break
+			}
+		}
+		if save == nil {
+			saves = append(saves, relationSave{rel: rel})
+			save = &saves[len(saves)-1]
+		}
+
+		if nested != "" {
+			save.nested = append(save.nested, nested)
+		}
+	}
+	return saves, nil
+}
+
+// isOwnedByBase reports whether the base table holds the foreign key of the relation,
+// which means that the related rows must be saved before the base rows.
+func isOwnedByBase(rel *schema.Relation) bool {
+	switch rel.Type {
+	case schema.BelongsToRelation:
+		return true
+	case schema.HasOneRelation:
+		return allPKs(rel.JoinPKs) && !allPKs(rel.BasePKs)
+	default:
+		return false
+	}
+}
+
+func allPKs(fields []*schema.Field) bool {
+	for _, f := range fields {
+		if !f.IsPK {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+//------------------------------------------------------------------------------
+
+// saveRelationsBefore saves belongs-to relations and copies the primary keys
+// of the saved rows into the foreign keys of the base rows.
+func (q *baseQuery) saveRelationsBefore(ctx context.Context, paths []string) error {
+	saves, err := q.relationSaves(paths)
+	if err != nil {
+		return err
+	}
+
+	for _, save := range saves {
+		if !isOwnedByBase(save.rel) {
+			continue
+		}
+
+		rel := save.rel
+		bases, joins := q.relationValues(rel)
+		if len(joins) == 0 {
+			continue
+		}
+
+		if err := q.saveRelated(ctx, rel.JoinTable, joins, save.nested); err != nil {
+			return err
+		}
+
+		for i, base := range bases {
+			join := joins[i]
+			for j, baseField := range rel.BasePKs {
+				if err := copyFieldValue(
+					baseField.Value(base), rel.JoinPKs[j].Value(join),
+				); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveRelationsAfter saves has-one, has-many, and m2m relations using
+// the primary keys of the base rows that were saved by the query.
+func (q *baseQuery) saveRelationsAfter(ctx context.Context, paths []string) error {
+	saves, err := q.relationSaves(paths)
+	if err != nil {
+		return err
+	}
+
+	for _, save := range saves {
+		if isOwnedByBase(save.rel) {
+			continue
+		}
+
+		rel := save.rel
+		bases, joins := q.relationValues(rel)
+		if len(joins) == 0 {
+			continue
+		}
+
+		if rel.Type != schema.ManyToManyRelation {
+			for i, join := range joins {
+				base := bases[i]
+				for j, baseField := range rel.BasePKs {
+					if err := copyFieldValue(
+						rel.JoinPKs[j].Value(join), baseField.Value(base),
+					); err != nil {
+						return err
+					}
+				}
+				if rel.PolymorphicField != nil {
+					if err := rel.PolymorphicField.ScanValue(join, rel.PolymorphicValue); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if err := q.saveRelated(ctx, rel.JoinTable, joins, save.nested); err != nil {
+			return err
+		}
+
+		if rel.Type == schema.ManyToManyRelation {
+			if err := q.insertM2MRows(ctx, rel, bases, joins); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runInRelationsTx runs fn in a transaction when the query is executed on the DB
+// or a Conn, so a failed query doesn't leave the relations partially saved.
+// The query is executed on the transaction while fn runs.
+func (q *baseQuery) runInRelationsTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var begin func(ctx context.Context) (Tx, error)
+	switch conn := q.conn.(type) {
+	case *sql.DB:
+		if conn != q.db.DB {
+			return fn(ctx)
+		}
+		begin = func(ctx context.Context) (Tx, error) {
+			return q.db.BeginTx(ctx, nil)
+		}
+	case *sql.Conn:
+		begin = func(ctx context.Context) (Tx, error) {
+			return Conn{db: q.db, Conn: conn}.BeginTx(ctx, nil)
+		}
+	default:
+		return fn(ctx)
+	}
+
+	conn := q.conn
+	defer func() {
+		q.conn = conn
+	}()
+
+	// The transaction is not retried, because fn runs the model hooks.
+	return runTxOnce(ctx, begin, func(ctx context.Context, tx Tx) error {
+		q.conn = tx.Tx
+		return fn(ctx)
+	})
+}
+
+func (q *baseQuery) relationSaves(paths []string) ([]relationSave, error) {
+	if q.table == nil {
+		return nil, fmt.Errorf("bun: WithRelations requires a model")
+	}
+	return relationSaves(q.table, paths)
+}
+
+// relationValues returns pairs of base and related structs.
+// The same base struct is returned once for each related struct.
+func (q *baseQuery) relationValues(rel *schema.Relation) (bases, joins []reflect.Value) {
+	walk(reflect.ValueOf(q.tableModel.Value()), nil, func(base reflect.Value) {
+		walk(base, rel.Field.Index, func(join reflect.Value) {
+			if join.IsValid() {
+				bases = append(bases, base)
+				joins = append(joins, join)
+			}
+		})
+	})
+	return bases, joins
+}
+
+// saveRelated inserts rows without a primary key and upserts the rest.
+func (q *baseQuery) saveRelated(
+	ctx context.Context, table *schema.Table, strcts []reflect.Value, nested []string,
+) error {
+	sliceType := reflect.SliceOf(reflect.PtrTo(table.Type))
+	inserts := reflect.MakeSlice(sliceType, 0, len(strcts))
+	upserts := reflect.MakeSlice(sliceType, 0, len(strcts))
+
+	seen := make(map[uintptr]struct{}, len(strcts))
+	for _, strct := range strcts {
+		ptr := strct.Addr()
+		if _, ok := seen[ptr.Pointer()]; ok {
+			continue
+		}
+		seen[ptr.Pointer()] = struct{}{}
+
+		if hasZeroPK(table, strct) {
+			inserts = reflect.Append(inserts, ptr)
+		} else {
+			upserts = reflect.Append(upserts, ptr)
+		}
+	}
+
+	if inserts.Len() > 0 {
+		if _, err := q.db.NewInsert().
+			Conn(q.conn).
+			Model(slicePtr(inserts)).
+			WithRelations(nested...).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	if upserts.Len() > 0 {
+		if err := q.upsertRelated(ctx, table, upserts, nested); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *baseQuery) upsertRelated(
+	ctx context.Context, table *schema.Table, slice reflect.Value, nested []string,
+) error {
+	switch {
+	case q.db.HasFeature(feature.InsertOnConflict):
+		b := make([]byte, 0, 32)
+		for i, pk := range table.PKs {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(b, pk.SQLName...)
+		}
+
+		insert := q.db.NewInsert().
+			Conn(q.conn).
+			Model(slicePtr(slice)).
+			WithRelations(nested...)
+		if len(table.DataFields) > 0 {
+			insert = insert.On("CONFLICT (?) DO UPDATE", Safe(b))
+		} else {
+			insert = insert.On("CONFLICT (?) DO NOTHING", Safe(b))
+		}
+		_, err := insert.Exec(ctx)
+		return err
+	case q.db.HasFeature(feature.InsertOnDuplicateKey):
+		_, err := q.db.NewInsert().
+			Conn(q.conn).
+			Model(slicePtr(slice)).
+			On("DUPLICATE KEY UPDATE").
+			WithRelations(nested...).
+			Exec(ctx)
+		return err
+	}
+
+	for i := 0; i < slice.Len(); i++ {
+		strct := slice.Index(i).Interface()
+
+		exists, err := q.db.NewSelect().
+			Conn(q.conn).
+			Model(strct).
+			WherePK().
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			if len(table.DataFields) == 0 {
+				continue
+			}
+			_, err = q.db.NewUpdate().
+				Conn(q.conn).
+				Model(strct).
+				WherePK().
+				WithRelations(nested...).
+				Exec(ctx)
+		} else {
+			_, err = q.db.NewInsert().
+				Conn(q.conn).
+				Model(strct).
+				WithRelations(nested...).
+				Exec(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertM2MRows inserts the missing rows into the m2m table. The rows are deduplicated
+// with ON CONFLICT DO NOTHING or INSERT IGNORE only when the m2m table has a unique key
+// on the relation columns; otherwise, existing rows are skipped with NOT EXISTS.
+func (q *baseQuery) insertM2MRows(
+	ctx context.Context, rel *schema.Relation, bases, joins []reflect.Value,
+) error {
+	fmter := q.db.formatter(ctx)
+	m2mTable := rel.M2MTable
+
+	var columns []byte
+	for i, f := range rel.M2MBasePKs {
+		if i > 0 {
+			columns = append(columns, ", "...)
+		}
+		columns = append(columns, f.SQLName...)
+	}
+	for _, f := range rel.M2MJoinPKs {
+		columns = append(columns, ", "...)
+		columns = append(columns, f.SQLName...)
+	}
+
+	appendValues := func(b []byte, base, join reflect.Value) []byte {
+		for i, f := range rel.BasePKs {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = f.AppendValue(fmter, b, base)
+		}
+		for _, f := range rel.JoinPKs {
+			b = append(b, ", "...)
+			b = f.AppendValue(fmter, b, join)
+		}
+		return b
+	}
+
+	if hasM2MUniqueKey(rel) &&
+		(fmter.HasFeature(feature.InsertOnConflict) || fmter.HasFeature(feature.InsertIgnore)) {
+		var b []byte
+		if fmter.HasFeature(feature.InsertOnConflict) {
+			b = append(b, "INSERT INTO "...)
+		} else {
+			b = append(b, "INSERT IGNORE INTO "...)
+		}
+		b = m2mTable.AppendName(fmter, b)
+		b = append(b, " ("...)
+		b = append(b, columns...)
+		b = append(b, ") VALUES "...)
+		for i := range bases {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(b, '(')
+			b = appendValues(b, bases[i], joins[i])
+			b = append(b, ')')
+		}
+		if fmter.HasFeature(feature.InsertOnConflict) {
+			b = append(b, " ON CONFLICT DO NOTHING"...)
+		}
+
+		_, err := q.db.NewRaw("?", Safe(b)).Conn(q.conn).Exec(ctx)
+		return err
+	}
+
+	for i := range bases {
+		var b []byte
+		b = append(b, "INSERT INTO "...)
+		b = m2mTable.AppendName(fmter, b)
+		b = append(b, " ("...)
+		b = append(b, columns...)
+		b = append(b, ") SELECT "...)
+		b = appendValues(b, bases[i], joins[i])
+		if fmter.Dialect().Name() == dialect.Oracle {
+			b = append(b, " FROM DUAL"...)
+		}
+		b = append(b, " WHERE NOT EXISTS (SELECT 1 FROM "...)
+		b = m2mTable.AppendName(fmter, b)
+		b = append(b, " WHERE "...)
+		for j, f := range rel.M2MBasePKs {
+			if j > 0 {
+				b = append(b, " AND "...)
+			}
+			b = append(b, f.SQLName...)
+			b = append(b, " = "...)
+			b = rel.BasePKs[j].AppendValue(fmter, b, bases[i])
+		}
+		for j, f := range rel.M2MJoinPKs {
+			b = append(b, " AND "...)
+			b = append(b, f.SQLName...)
+			b = append(b, " = "...)
+			b = rel.JoinPKs[j].AppendValue(fmter, b, joins[i])
+		}
+		b = append(b, ')')
+
+		if _, err := q.db.NewRaw("?", Safe(b)).Conn(q.conn).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasM2MUniqueKey reports whether the primary key or a unique group
+// of the m2m table consists of the columns of the relation.
+func hasM2MUniqueKey(rel *schema.Relation) bool {
+	fields := make([]*schema.Field, 0, len(rel.M2MBasePKs)+len(rel.M2MJoinPKs))
+	fields = append(fields, rel.M2MBasePKs...)
+	fields = append(fields, rel.M2MJoinPKs...)
+
+	if sameFields(rel.M2MTable.PKs, fields) {
+		return true
+	}
+	for name, unique := range rel.M2MTable.Unique {
+		// The fields without a group name are unique on their own.
+		if name != "" && sameFields(unique, fields) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFields(a, b []*schema.Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, f := range a {
+		if !containsField(b, f) {
+			return false
+		}
+	}
+	return true
+}
+
+//------------------------------------------------------------------------------
+
+func hasZeroPK(table *schema.Table, strct reflect.Value) bool {
+	if len(table.PKs) == 0 {
+		return true
+	}
+	for _, pk := range table.PKs {
+		if pk.HasZeroValue(strct) {
+			return true
+		}
+	}
+	return false
+}
+
+func slicePtr(slice reflect.Value) interface{} {
+	ptr := reflect.New(slice.Type())
+	ptr.Elem().Set(slice)
+	return ptr.Interface()
+}
+
+// copyFieldValue assigns src to dst converting between pointers and compatible types.
+func copyFieldValue(dst, src reflect.Value) error {
+	if src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		src = src.Elem()
+	}
+	if dst.Kind() == reflect.Ptr && dst.Type() != src.Type() {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	if !src.Type().ConvertibleTo(dst.Type()) {
+		return fmt.Errorf("bun: can't assign %s to %s", src.Type(), dst.Type())
+	}
+	dst.Set(src.Convert(dst.Type()))
+	return nil
+}
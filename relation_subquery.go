@@ -0,0 +1,82 @@
+package bun
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// relationSubquery returns a query that selects rows of the relation
+// correlated with the base table using the alias baseAlias.
+func (q *SelectQuery) relationSubquery(baseAlias schema.Safe, rel *schema.Relation) (*SelectQuery, error) {
+	joinTable := rel.JoinTable
+	if joinTable.SQLAlias == baseAlias {
+		return nil, fmt.Errorf(
+			"bun: %s and the base table have the same alias %s (use alias:name tag option)",
+			rel, baseAlias)
+	}
+
+	subq := q.db.NewSelect().
+		Conn(q.conn).
+		Model(reflect.New(joinTable.Type).Interface())
+
+	switch rel.Type {
+	case schema.ManyToManyRelation:
+		m2mTable := rel.M2MTable
+		subq = subq.Join("JOIN ? AS ?", tableName{m2mTable}, m2mTable.SQLAlias)
+		for i, m2mJoinField := range rel.M2MJoinPKs {
+			subq = subq.JoinOn("?.? = ?.?",
+				m2mTable.SQLAlias, m2mJoinField.SQLName,
+				joinTable.SQLAlias, rel.JoinPKs[i].SQLName)
+		}
+		for i, m2mBaseField := range rel.M2MBasePKs {
+			subq = subq.Where("?.? = ?.?",
+				m2mTable.SQLAlias, m2mBaseField.SQLName,
+				baseAlias, rel.BasePKs[i].SQLName)
+		}
+	default:
+		for i, baseField := range rel.BasePKs {
+			subq = subq.Where("?.? = ?.?",
+				joinTable.SQLAlias, rel.JoinPKs[i].SQLName,
+				baseAlias, baseField.SQLName)
+		}
+		if rel.PolymorphicField != nil {
+			subq = subq.Where("?.? = ?",
+				joinTable.SQLAlias, rel.PolymorphicField.SQLName, rel.PolymorphicValue)
+		}
+	}
+
+	for _, cond := range rel.Condition {
+		subq.addWhere(schema.SafeQueryWithSep(cond, nil, " AND "))
+	}
+
+	return subq, nil
+}
+
+// relationPathSubquery returns a subquery that selects rows of the last relation in the path.
+// Intermediate relations are joined using nested EXISTS conditions.
+func (q *SelectQuery) relationPathSubquery(
+	table *schema.Table, path []string, apply []func(*SelectQuery) *SelectQuery,
+) (*SelectQuery, error) {
+	rel, ok := table.Relations[path[0]]
+	if !ok {
+		return nil, fmt.Errorf("%s does not have relation=%q", table, path[0])
+	}
+
+	subq, err := q.relationSubquery(table.SQLAlias, rel)
+	if err != nil {
+		return nil, err
+	}
+	subq = subq.ColumnExpr("1")
+
+	if len(path) == 1 {
+		return subq.Apply(apply...), nil
+	}
+
+	nested, err := q.relationPathSubquery(rel.JoinTable, path[1:], apply)
+	if err != nil {
+		return nil, err
+	}
+	return subq.Where("EXISTS (?)", nested), nil
+}
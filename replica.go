@@ -0,0 +1,178 @@
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPolicy picks a replica for a read-only query from the healthy replicas.
+// It returns nil to execute the query on the primary.
+type ReplicaPolicy func(ctx context.Context, replicas []*sql.DB) *sql.DB
+
+// RoundRobinReplicas returns a policy that cycles through the replicas.
+func RoundRobinReplicas() ReplicaPolicy {
+	var next uint32
+	return func(ctx context.Context, replicas []*sql.DB) *sql.DB {
+		n := atomic.AddUint32(&next, 1)
+		return replicas[int(n-1)%len(replicas)]
+	}
+}
+
+// LeastConnReplicas returns a policy that picks the replica
+// with the fewest connections in use.
+func LeastConnReplicas() ReplicaPolicy {
+	return func(ctx context.Context, replicas []*sql.DB) *sql.DB {
+		var best *sql.DB
+		var bestInUse int
+		for _, replica := range replicas {
+			inUse := replica.Stats().InUse
+			if best == nil || inUse < bestInUse {
+				best, bestInUse = replica, inUse
+			}
+		}
+		return best
+	}
+}
+
+// WithReplicas registers read replicas of the primary database.
+//
+// Select queries that are not executed in a transaction or on a dedicated connection
+// and do not lock rows are sent to a replica picked by the policy (round-robin by default).
+// Use SelectQuery.UsePrimary or DB.WithPrimary to read from the primary.
+//
+// When a replica fails with a connection error, the query is retried on the primary
+// and the replica is not used for the next 30 seconds.
+func WithReplicas(policy ReplicaPolicy, replicas ...*sql.DB) DBOption {
+	return func(db *DB) {
+		if policy == nil {
+			policy = RoundRobinReplicas()
+		}
+		set := &replicaSet{
+			primary: db.DB,
+			policy:  policy,
+		}
+		for _, sqldb := range replicas {
+			set.replicas = append(set.replicas, &replica{set: set, db: sqldb})
+		}
+		db.replicas = set
+	}
+}
+
+// replicaDownTime is the time a failed replica is excluded from routing.
+const replicaDownTime = 30 * time.Second
+
+type primaryCtxKey struct{}
+
+// WithPrimary returns a context that makes select queries executed with it
+// read from the primary instead of a replica.
+func (db *DB) WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+//------------------------------------------------------------------------------
+
+type replicaSet struct {
+	primary  *sql.DB
+	policy   ReplicaPolicy
+	replicas []*replica
+}
+
+// conn returns a healthy replica picked by the policy or nil.
+func (s *replicaSet) conn(ctx context.Context) IConn {
+	healthy := make([]*sql.DB, 0, len(s.replicas))
+	for _, replica := range s.replicas {
+		if replica.isHealthy() {
+			healthy = append(healthy, replica.db)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	db := s.policy(ctx, healthy)
+	if db == nil {
+		return nil
+	}
+	for _, replica := range s.replicas {
+		if replica.db == db {
+			return replica
+		}
+	}
+	return db
+}
+
+// ReplicaConn is the connection of the queries routed to a replica, see QueryEvent.Conn.
+type ReplicaConn interface {
+	IConn
+	// Replica returns the replica the query is executed on.
+	Replica() *sql.DB
+}
+
+// replica executes queries on the replica and fails over to the primary
+// on connection errors.
+type replica struct {
+	set *replicaSet
+	db  *sql.DB
+
+	downUntil int64 // unix nanoseconds
+}
+
+var _ ReplicaConn = (*replica)(nil)
+
+func (r *replica) Replica() *sql.DB {
+	return r.db
+}
+
+func (r *replica) isHealthy() bool {
+	return time.Now().UnixNano() >= atomic.LoadInt64(&r.downUntil)
+}
+
+// failover marks the replica down and reports whether the query should be retried.
+func (r *replica) failover(ctx context.Context, err error) bool {
+	if !isConnError(err) || ctx.Err() != nil {
+		return false
+	}
+	atomic.StoreInt64(&r.downUntil, time.Now().Add(replicaDownTime).UnixNano())
+	return true
+}
+
+func (r *replica) QueryContext(
+	ctx context.Context, query string, args ...interface{},
+) (*sql.Rows, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil && r.failover(ctx, err) {
+		return r.set.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (r *replica) ExecContext(
+	ctx context.Context, query string, args ...interface{},
+) (sql.Result, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil && r.failover(ctx, err) {
+		return r.set.primary.ExecContext(ctx, query, args...)
+	}
+	return res, err
+}
+
+func (r *replica) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := r.db.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && r.failover(ctx, err) {
+		return r.set.primary.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+func isConnError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
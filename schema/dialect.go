@@ -44,6 +44,12 @@ type Dialect interface {
 	DefaultSchema() string
 }
 
+// TxRetryChecker is implemented by dialects that recognize errors after which
+// a transaction can be retried, for example, serialization failures and deadlocks.
+type TxRetryChecker interface {
+	IsTxRetryable(err error) bool
+}
+
 // ------------------------------------------------------------------------------
 
 type BaseDialect struct{}
@@ -34,6 +34,7 @@ type Field struct {
 	NullZero      bool
 	AutoIncrement bool
 	Identity      bool
+	Sensitive     bool
 
 	Append AppenderFunc
 	Scan   ScannerFunc
@@ -97,7 +98,15 @@ func (f *Field) HasZeroValue(v reflect.Value) bool {
 	return f.IsZero(v)
 }
 
+// SensitiveMask replaces the values of sensitive fields
+// in queries formatted with Formatter.WithSensitiveMask.
+const SensitiveMask = "'***'"
+
 func (f *Field) AppendValue(fmter Formatter, b []byte, strct reflect.Value) []byte {
+	if f.Sensitive && fmter.MasksSensitive() {
+		return append(b, SensitiveMask...)
+	}
+
 	fv, ok := fieldByIndex(strct, f.Index)
 	if !ok {
 		return dialect.AppendNull(b)
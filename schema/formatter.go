@@ -16,8 +16,10 @@ var nopFormatter = Formatter{
 }
 
 type Formatter struct {
-	dialect Dialect
-	args    *namedArgList
+	dialect       Dialect
+	args          *namedArgList
+	tableSchema   string
+	maskSensitive bool
 }
 
 func NewFormatter(dialect Dialect) Formatter {
@@ -63,17 +65,38 @@ func (f Formatter) HasFeature(feature feature.Feature) bool {
 }
 
 func (f Formatter) WithArg(arg NamedArgAppender) Formatter {
-	return Formatter{
-		dialect: f.dialect,
-		args:    f.args.WithArg(arg),
-	}
+	f.args = f.args.WithArg(arg)
+	return f
 }
 
 func (f Formatter) WithNamedArg(name string, value interface{}) Formatter {
-	return Formatter{
-		dialect: f.dialect,
-		args:    f.args.WithArg(&namedArg{name: name, value: value}),
-	}
+	f.args = f.args.WithArg(&namedArg{name: name, value: value})
+	return f
+}
+
+// WithTableSchema returns a copy of the formatter that qualifies model tables
+// with the schema unless the model specifies a schema itself.
+func (f Formatter) WithTableSchema(name string) Formatter {
+	f.tableSchema = name
+	return f
+}
+
+// TableSchema returns the schema set with WithTableSchema.
+func (f Formatter) TableSchema() string {
+	return f.tableSchema
+}
+
+// WithSensitiveMask returns a copy of the formatter that masks the values
+// of the fields with the sensitive tag option. The resulting queries
+// are meant for logging and can't be executed.
+func (f Formatter) WithSensitiveMask() Formatter {
+	f.maskSensitive = true
+	return f
+}
+
+// MasksSensitive reports whether the formatter masks sensitive values.
+func (f Formatter) MasksSensitive() bool {
+	return f.maskSensitive
 }
 
 func (f Formatter) FormatQuery(query string, args ...interface{}) string {
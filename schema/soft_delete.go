@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// SoftDeleteMarker controls how a soft_delete field marks rows as deleted.
+// Custom field types can implement it to use their own markers, for example,
+// a status column with a "deleted" value.
+type SoftDeleteMarker interface {
+	// SoftDeleteValue returns the value that marks a row deleted at the time tm.
+	SoftDeleteValue(tm time.Time) interface{}
+	// AppendSoftDeleteWhere appends a predicate for the column that matches
+	// deleted rows if deleted is true and the rest of the rows otherwise.
+	AppendSoftDeleteWhere(fmter Formatter, b []byte, column Safe, deleted bool) []byte
+}
+
+// SoftDeleteRestorer can be implemented by a SoftDeleteMarker to specify the value
+// that marks a restored row. By default, restored rows get NULL or the zero value.
+type SoftDeleteRestorer interface {
+	SoftRestoreValue() interface{}
+}
+
+var softDeleteMarkerType = reflect.TypeOf((*SoftDeleteMarker)(nil)).Elem()
+
+func newSoftDeleteMarker(field *Field) SoftDeleteMarker {
+	typ := field.IndirectType
+	if typ.Implements(softDeleteMarkerType) {
+		return reflect.Zero(typ).Interface().(SoftDeleteMarker)
+	}
+	if reflect.PointerTo(typ).Implements(softDeleteMarkerType) {
+		return reflect.New(typ).Interface().(SoftDeleteMarker)
+	}
+
+	nullable := field.IsPtr || field.NullZero
+	if typ.Kind() == reflect.Bool {
+		return boolSoftDelete{nullable: nullable}
+	}
+	return timeSoftDelete{nullable: nullable}
+}
+
+type timeSoftDelete struct {
+	nullable bool
+}
+
+func (m timeSoftDelete) SoftDeleteValue(tm time.Time) interface{} {
+	return tm
+}
+
+func (m timeSoftDelete) AppendSoftDeleteWhere(
+	fmter Formatter, b []byte, column Safe, deleted bool,
+) []byte {
+	b = append(b, column...)
+
+	if m.nullable {
+		if deleted {
+			return append(b, " IS NOT NULL"...)
+		}
+		return append(b, " IS NULL"...)
+	}
+
+	if deleted {
+		b = append(b, " != "...)
+	} else {
+		b = append(b, " = "...)
+	}
+	return fmter.Dialect().AppendTime(b, time.Time{})
+}
+
+type boolSoftDelete struct {
+	nullable bool
+}
+
+func (m boolSoftDelete) SoftDeleteValue(tm time.Time) interface{} {
+	return true
+}
+
+func (m boolSoftDelete) AppendSoftDeleteWhere(
+	fmter Formatter, b []byte, column Safe, deleted bool,
+) []byte {
+	if deleted {
+		b = append(b, column...)
+		b = append(b, " = "...)
+		return fmter.Dialect().AppendBool(b, true)
+	}
+
+	if m.nullable {
+		b = append(b, '(')
+		b = append(b, column...)
+		b = append(b, " IS NULL OR "...)
+	}
+	b = append(b, column...)
+	b = append(b, " = "...)
+	b = fmter.Dialect().AppendBool(b, false)
+	if m.nullable {
+		b = append(b, ')')
+	}
+	return b
+}
+
+// softDeleteFieldUpdater returns a function that marks the field value as deleted.
+func softDeleteFieldUpdater(field *Field, marker SoftDeleteMarker) func(fv reflect.Value, tm time.Time) error {
+	switch marker.(type) {
+	case timeSoftDelete:
+		return timeFieldUpdater(field)
+	}
+
+	return func(fv reflect.Value, tm time.Time) error {
+		v := reflect.ValueOf(marker.SoftDeleteValue(tm))
+		if fv.Kind() == reflect.Ptr && v.Type() != fv.Type() {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if !v.Type().ConvertibleTo(fv.Type()) {
+			return fmt.Errorf("bun: can't assign %s to soft delete field %s", v.Type(), field.GoName)
+		}
+		fv.Set(v.Convert(fv.Type()))
+		return nil
+	}
+}
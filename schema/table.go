@@ -65,8 +65,16 @@ type Table struct {
 	Unique    map[string][]*Field
 
 	SoftDeleteField       *Field
+	SoftDelete            SoftDeleteMarker
 	UpdateSoftDeleteField func(fv reflect.Value, tm time.Time) error
 
+	VersionField *Field
+
+	CreatedAtField       *Field
+	UpdateCreatedAtField func(fv reflect.Value, tm time.Time) error
+	UpdatedAtField       *Field
+	UpdateUpdatedAtField func(fv reflect.Value, tm time.Time) error
+
 	flags internal.Flag
 }
 
@@ -288,6 +296,31 @@ func (t *Table) String() string {
 	return "model=" + t.TypeName
 }
 
+// AppendName appends the table name using the schema of the formatter,
+// see Formatter.WithTableSchema.
+func (t *Table) AppendName(fmter Formatter, b []byte) []byte {
+	return appendTableName(fmter, b, t.SQLName)
+}
+
+// AppendNameForSelects is like AppendName, but uses the name for select queries.
+func (t *Table) AppendNameForSelects(fmter Formatter, b []byte) []byte {
+	return appendTableName(fmter, b, t.SQLNameForSelects)
+}
+
+func appendTableName(fmter Formatter, b []byte, name Safe) []byte {
+	if fmter.tableSchema != "" && !isQualifiedTableName(string(name)) {
+		b = fmter.AppendIdent(b, fmter.tableSchema)
+		b = append(b, '.')
+	}
+	return fmter.AppendQuery(b, string(name))
+}
+
+// isQualifiedTableName reports whether the name already specifies a schema
+// or is an expression, for example, a subquery.
+func isQualifiedTableName(name string) bool {
+	return strings.ContainsAny(name, ".()")
+}
+
 func (t *Table) CheckPKs() error {
 	if len(t.PKs) == 0 {
 		return fmt.Errorf("bun: %s does not have primary keys", t)
@@ -321,7 +354,28 @@ func (t *Table) addField(field *Field) {
 
 	if _, ok := field.Tag.Options["soft_delete"]; ok {
 		t.SoftDeleteField = field
-		t.UpdateSoftDeleteField = softDeleteFieldUpdater(field)
+		t.SoftDelete = newSoftDeleteMarker(field)
+		t.UpdateSoftDeleteField = softDeleteFieldUpdater(field, t.SoftDelete)
+	}
+
+	if field.Tag.HasOption("created_at") {
+		t.CreatedAtField = field
+		t.UpdateCreatedAtField = timeFieldUpdater(field)
+	}
+	if field.Tag.HasOption("updated_at") {
+		t.UpdatedAtField = field
+		t.UpdateUpdatedAtField = timeFieldUpdater(field)
+	}
+
+	if field.Tag.HasOption("version") {
+		switch field.IndirectType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			panic(fmt.Errorf("bun: %s.%s version field must be an integer, got %s",
+				t.TypeName, field.GoName, field.IndirectType))
+		}
+		t.VersionField = field
 	}
 
 	t.Fields = append(t.Fields, field)
@@ -483,6 +537,7 @@ func (t *Table) newField(sf reflect.StructField, tag tagparser.Tag) *Field {
 	if tag.HasOption("identity") {
 		field.Identity = true
 	}
+	field.Sensitive = tag.HasOption("sensitive")
 
 	if v, ok := tag.Options["unique"]; ok {
 		t.addUnique(field, "", v)
@@ -946,8 +1001,12 @@ func isKnownFieldOption(name string) bool {
 		"default",
 		"unique",
 		"soft_delete",
+		"version",
+		"created_at",
+		"updated_at",
 		"scanonly",
 		"skipupdate",
+		"sensitive",
 
 		"pk",
 		"autoincrement",
@@ -998,7 +1057,7 @@ func parseRelationJoin(join []string) ([]string, []string) {
 
 //------------------------------------------------------------------------------
 
-func softDeleteFieldUpdater(field *Field) func(fv reflect.Value, tm time.Time) error {
+func timeFieldUpdater(field *Field) func(fv reflect.Value, tm time.Time) error {
 	typ := field.StructField.Type
 
 	switch typ {
@@ -1032,7 +1091,7 @@ func softDeleteFieldUpdater(field *Field) func(fv reflect.Value, tm time.Time) e
 	case reflect.Ptr:
 		typ = typ.Elem()
 	default:
-		return softDeleteFieldUpdaterFallback(field)
+		return timeFieldUpdaterFallback(field)
 	}
 
 	switch typ { //nolint:gocritic
@@ -1052,10 +1111,10 @@ func softDeleteFieldUpdater(field *Field) func(fv reflect.Value, tm time.Time) e
 		}
 	}
 
-	return softDeleteFieldUpdaterFallback(field)
+	return timeFieldUpdaterFallback(field)
 }
 
-func softDeleteFieldUpdaterFallback(field *Field) func(fv reflect.Value, tm time.Time) error {
+func timeFieldUpdaterFallback(field *Field) func(fv reflect.Value, tm time.Time) error {
 	return func(fv reflect.Value, tm time.Time) error {
 		return field.ScanWithCheck(fv, tm)
 	}
@@ -0,0 +1,97 @@
+package bun
+
+import (
+	"sort"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// DefaultScoper is implemented by models that register named default scopes,
+// for example, to filter rows by a tenant.
+//
+// The scopes are applied to select, update, and delete queries of the model,
+// including the queries that load has-many and m2m relations. Relations that
+// are loaded with a JOIN are not scoped. Use WithoutScope to disable a scope.
+type DefaultScoper interface {
+	DefaultScopes() map[string]func(QueryBuilder) QueryBuilder
+}
+
+func (q *whereBaseQuery) withoutScope(names []string) {
+	q.withoutScopes = append(q.withoutScopes, names...)
+	q.scopesCollected = false
+}
+
+func (q *whereBaseQuery) hasScope(name string) bool {
+	for _, s := range q.withoutScopes {
+		if s == name {
+			return false
+		}
+	}
+	return true
+}
+
+// collectScopes collects the conditions of the default scopes of the model.
+// newQuery must return an empty query of the same kind that shares
+// the model with q, so scopes can use QueryBuilder.Unwrap.
+func (q *whereBaseQuery) collectScopes(newQuery func() (QueryBuilder, *whereBaseQuery)) error {
+	if q.scopesCollected {
+		return nil
+	}
+	q.scopeWhere = nil
+
+	if q.table == nil {
+		return nil
+	}
+	scoper, ok := q.table.ZeroIface.(DefaultScoper)
+	if !ok {
+		return nil
+	}
+
+	scopes := scoper.DefaultScopes()
+	names := make([]string, 0, len(scopes))
+	for name := range scopes {
+		if q.hasScope(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		qb, scoped := newQuery()
+		scopes[name](qb)
+		if scoped.err != nil {
+			return scoped.err
+		}
+
+		switch len(scoped.where) {
+		case 0:
+		case 1:
+			where := scoped.where[0]
+			where.Sep = " AND "
+			q.scopeWhere = append(q.scopeWhere, where)
+		default:
+			where := scoped.where
+			q.scopeWhere = append(q.scopeWhere,
+				schema.SafeQueryWithSep("", nil, " AND "),
+				schema.SafeQueryWithSep("", nil, "("))
+			where[0].Sep = ""
+			q.scopeWhere = append(q.scopeWhere, where...)
+			q.scopeWhere = append(q.scopeWhere, schema.SafeQueryWithSep("", nil, ")"))
+		}
+	}
+	q.scopesCollected = true
+	return nil
+}
+
+// scopeQuery returns an empty where query that shares the model with q.
+func (q *whereBaseQuery) scopeQuery() whereBaseQuery {
+	return whereBaseQuery{
+		baseQuery: baseQuery{
+			db:         q.db,
+			conn:       q.conn,
+			model:      q.model,
+			tableModel: q.tableModel,
+			table:      q.table,
+		},
+	}
+}
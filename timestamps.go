@@ -0,0 +1,68 @@
+package bun
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// TimestampsModel can be embedded in a model to manage created_at and updated_at columns.
+// Insert queries set both columns and update queries refresh the updated_at column.
+type TimestampsModel struct {
+	CreatedAt time.Time `bun:",created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// timestamp returns the time set on the model before the query was executed
+// or the current time when the query is formatted without executing it.
+func (q *baseQuery) timestamp() time.Time {
+	if !q.now.IsZero() {
+		return q.now
+	}
+	return q.db.now()
+}
+
+// updateTimestamps sets zero created_at and updated_at fields of the model on insert
+// and refreshes updated_at fields on update.
+func (q *baseQuery) updateTimestamps(tm time.Time, insert bool) error {
+	if q.tableModel == nil {
+		return nil
+	}
+
+	table := q.table
+	if table.CreatedAtField == nil && table.UpdatedAtField == nil {
+		return nil
+	}
+
+	var firstErr error
+	walk(reflect.ValueOf(q.tableModel.Value()), nil, func(strct reflect.Value) {
+		if firstErr != nil || !strct.IsValid() {
+			return
+		}
+		if insert {
+			firstErr = updateTimeField(table.CreatedAtField, table.UpdateCreatedAtField, strct, tm, true)
+			if firstErr != nil {
+				return
+			}
+		}
+		firstErr = updateTimeField(table.UpdatedAtField, table.UpdateUpdatedAtField, strct, tm, insert)
+	})
+	return firstErr
+}
+
+func updateTimeField(
+	field *schema.Field,
+	update func(fv reflect.Value, tm time.Time) error,
+	strct reflect.Value,
+	tm time.Time,
+	onlyZero bool,
+) error {
+	if field == nil {
+		return nil
+	}
+	if onlyZero && !field.HasZeroValue(strct) {
+		return nil
+	}
+	return update(field.Value(strct), tm)
+}
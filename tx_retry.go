@@ -0,0 +1,99 @@
+package bun
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun/schema"
+)
+
+type txRetry struct {
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+}
+
+// WithTxRetry makes RunInTx execute the function again with a new transaction
+// when the transaction fails with an error that the dialect recognizes as retryable,
+// for example, a serialization failure or a deadlock. See schema.TxRetryChecker.
+//
+// The function is executed at most maxAttempts times. backoff returns the delay
+// before the next attempt; it can be nil to retry immediately. Use QueryEvent.TxAttempt
+// to see the attempt in query hooks. Savepoints started with Tx.RunInTx are not retried.
+func WithTxRetry(maxAttempts int, backoff func(attempt int) time.Duration) DBOption {
+	return func(db *DB) {
+		db.txRetry = txRetry{
+			maxAttempts: maxAttempts,
+			backoff:     backoff,
+		}
+	}
+}
+
+type txAttemptCtxKey struct{}
+
+// txAttempt returns the attempt of the transaction retried by RunInTx or 0.
+func txAttempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(txAttemptCtxKey{}).(int)
+	return attempt
+}
+
+func (db *DB) runInTx(
+	ctx context.Context,
+	begin func(ctx context.Context) (Tx, error),
+	fn func(ctx context.Context, tx Tx) error,
+) error {
+	cfg := db.txRetry
+	if cfg.maxAttempts <= 1 {
+		return runTxOnce(ctx, begin, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runTxOnce(context.WithValue(ctx, txAttemptCtxKey{}, attempt), begin, fn)
+		if err == nil || attempt >= cfg.maxAttempts || !db.isTxRetryable(err) {
+			return err
+		}
+
+		if cfg.backoff != nil {
+			timer := time.NewTimer(cfg.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+func (db *DB) isTxRetryable(err error) bool {
+	if checker, ok := db.dialect.(schema.TxRetryChecker); ok {
+		return checker.IsTxRetryable(err)
+	}
+	return false
+}
+
+func runTxOnce(
+	ctx context.Context,
+	begin func(ctx context.Context) (Tx, error),
+	fn func(ctx context.Context, tx Tx) error,
+) error {
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	var done bool
+
+	defer func() {
+		if !done {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Use the context of the transaction so query hooks can link queries to it.
+	if err := fn(tx.ctx, tx); err != nil {
+		return err
+	}
+
+	done = true
+	return tx.Commit()
+}
@@ -1,6 +1,10 @@
 package bun
 
-import "reflect"
+import (
+	"reflect"
+
+	"github.com/uptrace/bun/schema"
+)
 
 func indirect(v reflect.Value) reflect.Value {
 	switch v.Kind() {
@@ -66,3 +70,12 @@ func sliceElemType(v reflect.Value) reflect.Type {
 	}
 	return indirectType(elemType)
 }
+
+func containsField(fields []*schema.Field, field *schema.Field) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
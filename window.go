@@ -0,0 +1,177 @@
+package bun
+
+import (
+	"github.com/uptrace/bun/schema"
+)
+
+// WindowDef describes a window specification that is used by a named `WINDOW` clause
+// or by an inline `OVER (...)` expression.
+type WindowDef struct {
+	base      string
+	partition []schema.QueryWithArgs
+	order     []schema.QueryWithArgs
+	frame     schema.QueryWithArgs
+}
+
+var _ schema.QueryAppender = (*WindowDef)(nil)
+
+// Base makes the window inherit the definition of an existing named window,
+// for example, `WINDOW w2 AS (w1 ORDER BY id)`.
+func (w *WindowDef) Base(window string) *WindowDef {
+	w.base = window
+	return w
+}
+
+func (w *WindowDef) Partition(columns ...string) *WindowDef {
+	for _, column := range columns {
+		w.partition = append(w.partition, schema.UnsafeIdent(column))
+	}
+	return w
+}
+
+func (w *WindowDef) PartitionExpr(query string, args ...interface{}) *WindowDef {
+	w.partition = append(w.partition, schema.SafeQuery(query, args))
+	return w
+}
+
+func (w *WindowDef) Order(orders ...string) *WindowDef {
+	w.order = appendOrder(w.order, orders...)
+	return w
+}
+
+func (w *WindowDef) OrderExpr(query string, args ...interface{}) *WindowDef {
+	w.order = append(w.order, schema.SafeQuery(query, args))
+	return w
+}
+
+// Frame sets the frame clause, for example,
+// `ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW`.
+func (w *WindowDef) Frame(frame string, args ...interface{}) *WindowDef {
+	w.frame = schema.SafeQuery(frame, args)
+	return w
+}
+
+// AppendQuery appends the parenthesized window specification.
+func (w *WindowDef) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	b = append(b, '(')
+	start := len(b)
+
+	if w.base != "" {
+		b = fmter.AppendIdent(b, w.base)
+	}
+
+	if len(w.partition) > 0 {
+		if len(b) > start {
+			b = append(b, ' ')
+		}
+		b = append(b, "PARTITION BY "...)
+		for i, f := range w.partition {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b, err = f.AppendQuery(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(w.order) > 0 {
+		if len(b) > start {
+			b = append(b, ' ')
+		}
+		b = append(b, "ORDER BY "...)
+		for i, f := range w.order {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b, err = f.AppendQuery(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !w.frame.IsZero() {
+		if len(b) > start {
+			b = append(b, ' ')
+		}
+		b, err = w.frame.AppendQuery(fmter, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b = append(b, ')')
+	return b, nil
+}
+
+//------------------------------------------------------------------------------
+
+type namedWindow struct {
+	name string
+	def  *WindowDef
+}
+
+func (w namedWindow) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	b = fmter.AppendIdent(b, w.name)
+	b = append(b, " AS "...)
+	return w.def.AppendQuery(fmter, b)
+}
+
+//------------------------------------------------------------------------------
+
+type overExpr struct {
+	name string
+	def  *WindowDef
+}
+
+var _ schema.QueryAppender = (*overExpr)(nil)
+
+// Over returns an `OVER window` expression that references a window
+// defined with SelectQuery.Window, for example:
+//
+//	q.ColumnExpr("row_number() ? AS rank", bun.Over("w"))
+func Over(window string) schema.QueryAppender {
+	return &overExpr{name: window}
+}
+
+// OverWindow returns an `OVER (...)` expression with an inline window specification.
+func OverWindow(fn func(*WindowDef)) schema.QueryAppender {
+	def := new(WindowDef)
+	fn(def)
+	return &overExpr{def: def}
+}
+
+func (e *overExpr) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	b = append(b, "OVER "...)
+	if e.def != nil {
+		return e.def.AppendQuery(fmter, b)
+	}
+	return fmter.AppendIdent(b, e.name), nil
+}
+
+//------------------------------------------------------------------------------
+
+type filterExpr struct {
+	where schema.QueryWithArgs
+}
+
+var _ schema.QueryAppender = (*filterExpr)(nil)
+
+// Filter returns a `FILTER (WHERE ...)` clause for aggregate functions, for example:
+//
+//	q.ColumnExpr("count(*) ? AS num_paid", bun.Filter("status = ?", "paid"))
+func Filter(query string, args ...interface{}) schema.QueryAppender {
+	return &filterExpr{where: schema.SafeQuery(query, args)}
+}
+
+func (e *filterExpr) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	b = append(b, "FILTER (WHERE "...)
+	b, err = e.where.AppendQuery(fmter, b)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, ')')
+	return b, nil
+}